@@ -1,21 +1,73 @@
 package main
 
 import (
+	"errors"
+	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
+	lot_config "github.com/dladlk/liftoff-telemetry/data"
+	lot_records "github.com/dladlk/liftoff-telemetry/records"
+	lot_source "github.com/dladlk/liftoff-telemetry/source"
 	"github.com/pelletier/go-toml/v2"
 )
 
+const DEFAULT_CONFIG_PATH = "liftoff-telemetry.toml.ini"
+
+// DEFAULT_FILE_NAME is the template of recorded file names, extension is added by format
+const DEFAULT_FILE_NAME = "liftoff_telemetry_{time}"
+
 type Config struct {
 	General struct {
 		Save        bool   `toml:"save"`
 		SaveEachNth int32  `toml:"saveEachNth"`
 		Format      string `toml:"format"`
+		Derived     bool   `toml:"derived"`
+		Track       string `toml:"track"`
+		Drone       string `toml:"drone"`
+		// Time above each of speeds in meters/second is reported
+		SpeedThresholds []float64 `toml:"speedThresholds"`
 	} `toml:"general"`
+	Output struct {
+		Dir      string `toml:"dir"`
+		FileName string `toml:"fileName"`
+	} `toml:"output"`
 	Log struct {
-		LogToFile bool `toml:"logToFile"`
-		Debug     bool `toml:"debug"`
+		LogToFile bool   `toml:"logToFile"`
+		File      string `toml:"file"`
+		Debug     bool   `toml:"debug"`
 	} `toml:"log"`
+	Liftoff struct {
+		TelemetryConfig string   `toml:"telemetryConfig"`
+		Endpoint        string   `toml:"endpoint"`
+		StreamFormat    []string `toml:"streamFormat"`
+	} `toml:"liftoff"`
+	Tracks  []lot_config.Track `toml:"tracks"`
+	Records struct {
+		Dir string `toml:"dir"`
+		Top int    `toml:"top"`
+	} `toml:"records"`
+	Relay struct {
+		Targets []lot_source.RelayTarget `toml:"targets"`
+		Record  bool                     `toml:"record"`
+	} `toml:"relay"`
+}
+
+// DefaultConfig returns values used for settings missing in config file and flags
+func DefaultConfig() *Config {
+	c := Config{}
+	c.General.Save = true
+	c.General.Format = "csv"
+	c.Output.FileName = DEFAULT_FILE_NAME
+	c.Log.File = os.Args[0] + ".log"
+	c.General.Drone = lot_records.DefaultDrone
+	c.General.SpeedThresholds = lot_config.DefaultSpeedThresholds
+	c.Records.Dir = "records"
+	c.Records.Top = lot_records.DefaultTop
+	c.Relay.Record = true
+	return &c
 }
 
 func LoadConfig(path string) (*Config, error) {
@@ -23,11 +75,71 @@ func LoadConfig(path string) (*Config, error) {
 	if err != nil {
 		return nil, err
 	}
-	c := Config{}
-	c.General.Format = "csv"
+	c := DefaultConfig()
+
+	if err := toml.Unmarshal(b, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Validate checks values which cannot be used for recording
+func (c *Config) Validate() error {
+	var problems []string
+	if c.General.Format != "csv" && c.General.Format != "bin" {
+		problems = append(problems, fmt.Sprintf("format '%s' is not supported, use csv or bin", c.General.Format))
+	}
+	if c.General.SaveEachNth < 0 {
+		problems = append(problems, fmt.Sprintf("saveEachNth %d must not be negative", c.General.SaveEachNth))
+	}
+	if c.Output.FileName == "" || strings.ContainsAny(c.Output.FileName, `/\`) {
+		problems = append(problems, fmt.Sprintf("file name '%s' must not be empty or contain path, set output dir instead", c.Output.FileName))
+	}
+	for _, threshold := range c.General.SpeedThresholds {
+		if threshold <= 0 {
+			problems = append(problems, fmt.Sprintf("speed threshold %g must be positive", threshold))
+		}
+	}
+	if c.Records.Top < 1 {
+		problems = append(problems, fmt.Sprintf("records top %d must be positive", c.Records.Top))
+	}
+	for _, track := range c.Tracks {
+		if err := track.Validate(); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	if c.General.Track != "" && lot_config.FindTrack(c.Tracks, c.General.Track, nil) == nil {
+		problems = append(problems, fmt.Sprintf("track '%s' is not defined in [[tracks]]", c.General.Track))
+	}
+	if len(problems) > 0 {
+		return errors.New("Invalid config: " + strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// FilePath returns path of a new recorded file created at given time, without uniqueness suffix and extension
+func (c *Config) FilePath(t time.Time) string {
+	name := strings.ReplaceAll(c.Output.FileName, "{time}", t.Format("20060102_150405"))
+	name = strings.ReplaceAll(name, "{format}", c.General.Format)
+	return filepath.Join(c.Output.Dir, name)
+}
 
-	if err := toml.Unmarshal(b, &c); err != nil {
+// LiftoffTelemetryConfig returns inline Liftoff telemetry config if both endpoint and stream format are set,
+// otherwise reads TelemetryConfiguration.json from configured path or default locations.
+// If only one of them is set, it overrides the value of the file, which is not watched for changes then.
+func (c *Config) LiftoffTelemetryConfig() (*lot_config.LiftoffTelemetryConfig, error) {
+	if c.Liftoff.Endpoint != "" && len(c.Liftoff.StreamFormat) > 0 {
+		return lot_config.NewLiftoffTelemetryConfig(c.Liftoff.Endpoint, c.Liftoff.StreamFormat), nil
+	}
+	lotConfig, err := lot_config.ReadLiftoffTelemetryConfigFrom(c.Liftoff.TelemetryConfig)
+	if err != nil {
 		return nil, err
 	}
-	return &c, nil
+	if c.Liftoff.Endpoint != "" {
+		return lot_config.NewLiftoffTelemetryConfig(c.Liftoff.Endpoint, lotConfig.StreamFormatNames), nil
+	}
+	if len(c.Liftoff.StreamFormat) > 0 {
+		return lot_config.NewLiftoffTelemetryConfig(lotConfig.Endpoint, c.Liftoff.StreamFormat), nil
+	}
+	return lotConfig, nil
 }
@@ -2,17 +2,17 @@ package lot_config
 
 import (
 	"encoding/json"
-	"errors"
 	"fmt"
 	"io"
 	"os"
 )
 
 type LiftoffTelemetryConfig struct {
-	Endpoint          string   `json:"EndPoint"`
-	StreamFormatNames []string `json:"StreamFormat"`
-	StreamFormats     []StreamDataType
-	StreamFormatsMap  map[StreamDataType]string
+	Endpoint          string                    `json:"EndPoint"`
+	StreamFormatNames []string                  `json:"StreamFormat"`
+	StreamFormats     []StreamDataType          `json:"-"`
+	StreamFormatsMap  map[StreamDataType]string `json:"-"`
+	Path              string                    `json:"-"` // Empty if config is not read from file
 }
 
 func (t LiftoffTelemetryConfig) String() string {
@@ -61,12 +61,24 @@ func (t *LiftoffTelemetryConfig) UpdateStreamFormats() {
 	}
 }
 
+// NewLiftoffTelemetryConfig creates config from inline endpoint and stream format, without reading Liftoff config file
+func NewLiftoffTelemetryConfig(endpoint string, streamFormatNames []string) *LiftoffTelemetryConfig {
+	config := LiftoffTelemetryConfig{Endpoint: endpoint, StreamFormatNames: streamFormatNames}
+	config.UpdateStreamFormats()
+	return &config
+}
+
+// ReadLiftoffTelemetryConfig discovers TelemetryConfiguration.json in default locations and reads it
 func ReadLiftoffTelemetryConfig() (*LiftoffTelemetryConfig, error) {
-	userProfile := os.Getenv("USERPROFILE") // For Windows, use "HOME" or similar for Unix
-	if userProfile == "" {
-		return nil, errors.New("Cannot resolve %USERPROFILE% env variable")
+	return ReadLiftoffTelemetryConfigFrom("")
+}
+
+// ReadLiftoffTelemetryConfigFrom reads TelemetryConfiguration.json from explicit path or, if it is empty, from the first found default location
+func ReadLiftoffTelemetryConfigFrom(explicitPath string) (*LiftoffTelemetryConfig, error) {
+	telemetryConfigurationPath, err := FindTelemetryConfig(explicitPath)
+	if err != nil {
+		return nil, err
 	}
-	telemetryConfigurationPath := fmt.Sprintf(`%s\AppData\LocalLow\LuGus Studios\Liftoff\TelemetryConfiguration.json`, userProfile)
 	file, err := os.Open(telemetryConfigurationPath)
 	if err != nil {
 		return nil, err
@@ -78,7 +90,10 @@ func ReadLiftoffTelemetryConfig() (*LiftoffTelemetryConfig, error) {
 	}
 
 	var config LiftoffTelemetryConfig
-	json.Unmarshal(bytes, &config)
+	if err := json.Unmarshal(bytes, &config); err != nil {
+		return nil, fmt.Errorf("Failed to parse %s: %w", telemetryConfigurationPath, err)
+	}
+	config.Path = telemetryConfigurationPath
 	config.UpdateStreamFormats()
 
 	return &config, nil
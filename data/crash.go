@@ -0,0 +1,168 @@
+package lot_config
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+)
+
+// CrashCause is a set of signals which detected the crash
+type CrashCause int
+
+const (
+	// CrashImpact is sudden change of velocity
+	CrashImpact CrashCause = 1 << iota
+	// CrashGyroSpike is rotation faster than the drone can do by itself
+	CrashGyroSpike
+	// CrashMotorStop is a motor stopped while throttle is high, e.g. broken propeller
+	CrashMotorStop
+	// CrashUpsideDown is the drone lying upside down
+	CrashUpsideDown
+)
+
+var crashCauseNames = [...]string{"impact", "gyro spike", "motor stop", "upside down"}
+
+func (c CrashCause) String() string {
+	var names []string
+	for i, name := range crashCauseNames {
+		if c&(1<<i) != 0 {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+func (c CrashCause) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+type CrashSeverity int
+
+const (
+	CrashLight CrashSeverity = iota
+	CrashHard
+	CrashDestroyed
+)
+
+var crashSeverityNames = [...]string{"light", "hard", "destroyed"}
+
+func (s CrashSeverity) String() string {
+	if int(s) < len(crashSeverityNames) {
+		return crashSeverityNames[s]
+	}
+	return "unknown"
+}
+
+func (s CrashSeverity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+type Crash struct {
+	Timestamp float32       `json:"timestamp"`
+	Position  [3]float32    `json:"position"`
+	Impact    float64       `json:"impact"` // Velocity change at the impact, meters/second
+	Severity  CrashSeverity `json:"severity"`
+	Cause     CrashCause    `json:"cause"`
+}
+
+func (c Crash) String() string {
+	return fmt.Sprintf("%v crash at %.2f s %v, impact %.1f m/s (%v)", c.Severity, c.Timestamp, c.Position, c.Impact, c.Cause)
+}
+
+const (
+	// Deceleration in meters/second² and minimal velocity change in meters/second of an impact
+	crashDeceleration = 10 * Gravity
+	crashMinImpact    = 3.0
+	// Impact in meters/second from which crash is hard or destroys the drone
+	crashHardImpact      = 8.0
+	crashDestroyedImpact = 20.0
+	// Angular rate magnitude in degrees/second, faster rotation is tumbling after a hit
+	crashGyroSpike = 2000.0
+	// Throttle input, -1 to 1, above which all motors must spin
+	crashHighThrottle = 0.5
+	crashMotorStopped = 100.0
+	// Speed in meters/second and time in seconds of lying upside down
+	crashUpsideDownSpeed = 1.0
+	crashUpsideDownTime  = 0.5
+	// Signals within this time in seconds after a crash belong to it
+	crashCooldown = 1.0
+)
+
+// CrashDetector detects crashes from consecutive datagrams by impacts, gyro spikes, stopped motors and lying upside down.
+// Velocity change and speed are taken from Kinematics of the datagram.
+type CrashDetector struct {
+	hasAttitude bool
+
+	upsideDown float32 // Timestamp since the drone is upside down and still, negative if it is not
+	lastCrash  float32
+	crashed    bool
+	persistent CrashCause
+}
+
+func NewCrashDetector(fields []StreamDataType) *CrashDetector {
+	return &CrashDetector{hasAttitude: slices.Contains(fields, Attitude), upsideDown: -1}
+}
+
+// Reset forgets previous crashes, e.g. when race is restarted
+func (c *CrashDetector) Reset() {
+	*c = CrashDetector{hasAttitude: c.hasAttitude, upsideDown: -1}
+}
+
+// Update returns a crash if it is detected by the datagram with its kinematics and is not a continuation of the previous one
+func (c *CrashDetector) Update(d *Datagram, k *Kinematics) (Crash, bool) {
+	crash := Crash{Timestamp: d.Timestamp, Position: d.Position, Impact: k.VelocityChange}
+	if k.Interval > 0 && crash.Impact >= crashMinImpact && crash.Impact/k.Interval >= crashDeceleration {
+		crash.Cause |= CrashImpact
+	}
+	if math.Sqrt(sq(float64(d.Gyro[0]))+sq(float64(d.Gyro[1]))+sq(float64(d.Gyro[2]))) >= crashGyroSpike {
+		crash.Cause |= CrashGyroSpike
+	}
+	if d.Input[0] >= crashHighThrottle {
+		for _, rpm := range d.MotorRPM {
+			if math.Abs(float64(rpm)) < crashMotorStopped {
+				crash.Cause |= CrashMotorStop
+				break
+			}
+		}
+	}
+	if c.hasAttitude && k.HasVelocity && k.Speed < crashUpsideDownSpeed && d.UpsideDown() {
+		if c.upsideDown < 0 {
+			c.upsideDown = d.Timestamp
+		}
+		if d.Timestamp-c.upsideDown >= crashUpsideDownTime {
+			crash.Cause |= CrashUpsideDown
+		}
+	} else {
+		c.upsideDown = -1
+	}
+
+	// Motor stop and lying upside down last, they are reported once when they begin
+	persistent := crash.Cause & (CrashMotorStop | CrashUpsideDown)
+	begun := persistent &^ c.persistent
+	c.persistent = persistent
+	transient := crash.Cause & (CrashImpact | CrashGyroSpike)
+	if transient == 0 && begun == 0 {
+		return Crash{}, false
+	}
+	// Tumbling and lying after the hit are the same crash
+	if c.crashed && d.Timestamp >= c.lastCrash && d.Timestamp-c.lastCrash < crashCooldown {
+		if transient != 0 {
+			c.lastCrash = d.Timestamp
+		}
+		return Crash{}, false
+	}
+	c.crashed = true
+	c.lastCrash = d.Timestamp
+	switch {
+	case crash.Cause&CrashMotorStop != 0 || crash.Impact >= crashDestroyedImpact:
+		crash.Severity = CrashDestroyed
+	case crash.Impact >= crashHardImpact || crash.Cause&(CrashGyroSpike|CrashUpsideDown) != 0:
+		crash.Severity = CrashHard
+	}
+	return crash, true
+}
+
+func sq(v float64) float64 {
+	return v * v
+}
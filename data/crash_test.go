@@ -0,0 +1,113 @@
+package lot_config_test
+
+import (
+	"math"
+	"testing"
+
+	lot_config "github.com/dladlk/liftoff-telemetry/data"
+)
+
+func TestCrashDetector(t *testing.T) {
+	const dt = 0.01
+	upright := [4]float32{0, 0, 0, 1}
+	upsideDown := [4]float32{0, 0, 1, 0}
+	spinning := []float32{4000, 4000, 4000, 4000}
+
+	// sequence returns datagrams every dt with given velocities
+	sequence := func(velocities ...[3]float32) []lot_config.Datagram {
+		res := make([]lot_config.Datagram, len(velocities))
+		for i, v := range velocities {
+			res[i] = lot_config.Datagram{Timestamp: float32(i) * dt, Velocity: v, Attitude: upright, Input: [4]float32{0, 0, 0, 0}, MotorRPM: spinning}
+		}
+		return res
+	}
+	repeat := func(v [3]float32, n int) [][3]float32 {
+		res := make([][3]float32, n)
+		for i := range res {
+			res[i] = v
+		}
+		return res
+	}
+	fast, stop := [3]float32{0, 0, 15}, [3]float32{}
+
+	turn := make([][3]float32, 50)
+	for i := range turn {
+		angle := float64(i) / float64(len(turn)) * math.Pi / 2
+		turn[i] = [3]float32{float32(10 * math.Sin(angle)), 0, float32(10 * math.Cos(angle))}
+	}
+
+	tumbling := sequence(append(repeat(fast, 5), repeat(stop, 50)...)...)
+	for i := 5; i < 40; i++ {
+		tumbling[i].Gyro = [3]float32{3000, 0, 0}
+	}
+
+	motorStop := sequence(repeat(fast, 20)...)
+	for i := range motorStop {
+		motorStop[i].Input[0] = 1
+		if i >= 10 {
+			motorStop[i].MotorRPM = []float32{4000, 0, 4000, 4000}
+		}
+	}
+
+	lying := sequence(repeat(stop, 100)...)
+	for i := range lying {
+		lying[i].Attitude = upsideDown
+	}
+
+	tests := []struct {
+		name      string
+		datagrams []lot_config.Datagram
+		want      []lot_config.Crash
+	}{
+		{name: "Steady flight", datagrams: sequence(repeat(fast, 20)...)},
+		{name: "Sharp turn", datagrams: sequence(turn...)},
+		{
+			name:      "Wall hit",
+			datagrams: sequence(append(repeat(fast, 5), repeat(stop, 5)...)...),
+			want:      []lot_config.Crash{{Timestamp: 5 * dt, Impact: 15, Severity: lot_config.CrashHard, Cause: lot_config.CrashImpact}},
+		},
+		{
+			name:      "Light bump",
+			datagrams: sequence(append(repeat(fast, 5), repeat([3]float32{0, 0, 10}, 5)...)...),
+			want:      []lot_config.Crash{{Timestamp: 5 * dt, Impact: 5, Severity: lot_config.CrashLight, Cause: lot_config.CrashImpact}},
+		},
+		{
+			name:      "Tumbling after hit is one crash",
+			datagrams: tumbling,
+			want:      []lot_config.Crash{{Timestamp: 5 * dt, Impact: 15, Severity: lot_config.CrashHard, Cause: lot_config.CrashImpact | lot_config.CrashGyroSpike}},
+		},
+		{
+			name:      "Motor stop at full throttle",
+			datagrams: motorStop,
+			want:      []lot_config.Crash{{Timestamp: 10 * dt, Severity: lot_config.CrashDestroyed, Cause: lot_config.CrashMotorStop}},
+		},
+		{
+			name:      "Lying upside down",
+			datagrams: lying,
+			want:      []lot_config.Crash{{Timestamp: 50 * dt, Severity: lot_config.CrashHard, Cause: lot_config.CrashUpsideDown}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calculator := lot_config.NewKinematicsCalculator(allFields, lot_config.DefaultKinematicsSmoothing)
+			detector := lot_config.NewCrashDetector(allFields)
+			var got []lot_config.Crash
+			for i := range tt.datagrams {
+				kinematics := calculator.Update(&tt.datagrams[i])
+				if crash, ok := detector.Update(&tt.datagrams[i], &kinematics); ok {
+					got = append(got, crash)
+				}
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("Crashes = %v, want %v", got, tt.want)
+			}
+			for i, crash := range got {
+				want := tt.want[i]
+				if math.Abs(float64(crash.Timestamp-want.Timestamp)) > 1e-5 || math.Abs(crash.Impact-want.Impact) > 1e-3 ||
+					crash.Severity != want.Severity || crash.Cause != want.Cause {
+					t.Errorf("Crash %d = %v, want %v", i, crash, want)
+				}
+			}
+		})
+	}
+}
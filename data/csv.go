@@ -0,0 +1,255 @@
+package lot_config
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Csv columns of each field, MotorRPM has a column per motor named motor_rpm_1 and so on
+var csvColumnNames = [...][]string{
+	Timestamp: {"timestamp"},
+	Position:  {"pos_x", "pos_y", "pos_z"},
+	Attitude:  {"att_x", "att_y", "att_z", "att_w"},
+	Velocity:  {"vel_x", "vel_y", "vel_z"},
+	Gyro:      {"gyro_pitch", "gyro_roll", "gyro_yaw"},
+	Input:     {"input_throttle", "input_yaw", "input_pitch", "input_roll"},
+	Battery:   {"battery_v", "battery_pct"},
+}
+
+const csvMotorRPMPrefix = "motor_rpm_"
+
+// Fields of csv files written before the header was added, each of them in one column like [1 2 3]
+var legacyCsvFields = []StreamDataType{Timestamp, Position, Attitude, Velocity, Gyro, Input, MotorRPM}
+
+// CsvColumns returns header of csv the recorder writes: session and event number followed by each value of the fields
+func CsvColumns(fields []StreamDataType, motors int) []string {
+	columns := []string{"session", "event"}
+	for _, field := range fields {
+		if field == MotorRPM {
+			for i := 1; i <= motors; i++ {
+				columns = append(columns, csvMotorRPMPrefix+strconv.Itoa(i))
+			}
+			continue
+		}
+		if int(field) < len(csvColumnNames) {
+			columns = append(columns, csvColumnNames[field]...)
+		}
+	}
+	return columns
+}
+
+// AppendCsv appends session and event number and values of the fields as csv columns, without line end.
+// MotorRPM is cut or padded with zeros to the number of motors of the header.
+func (d *Datagram) AppendCsv(buf []byte, session int, event int32, fields []StreamDataType, motors int) []byte {
+	buf = strconv.AppendInt(buf, int64(session), 10)
+	buf = append(buf, ',')
+	buf = strconv.AppendInt(buf, int64(event), 10)
+	for _, field := range fields {
+		values := d.fieldValues(field)
+		if field == Timestamp {
+			buf = append(buf, ',')
+			buf = strconv.AppendFloat(buf, float64(d.Timestamp), 'g', -1, 32)
+			continue
+		}
+		if field == MotorRPM {
+			for i := 0; i < motors; i++ {
+				buf = append(buf, ',')
+				if i < len(values) {
+					buf = strconv.AppendFloat(buf, float64(values[i]), 'g', -1, 32)
+				} else {
+					buf = append(buf, '0')
+				}
+			}
+			continue
+		}
+		for _, v := range values {
+			buf = append(buf, ',')
+			buf = strconv.AppendFloat(buf, float64(v), 'g', -1, 32)
+		}
+	}
+	return buf
+}
+
+// fieldValues returns values of the field backed by the datagram, nil for Timestamp which is a single value
+func (d *Datagram) fieldValues(field StreamDataType) []float32 {
+	switch field {
+	case Position:
+		return d.Position[:]
+	case Attitude:
+		return d.Attitude[:]
+	case Velocity:
+		return d.Velocity[:]
+	case Gyro:
+		return d.Gyro[:]
+	case Input:
+		return d.Input[:]
+	case Battery:
+		return d.Battery[:]
+	case MotorRPM:
+		return d.MotorRPM
+	}
+	return nil
+}
+
+type csvColumn struct {
+	field StreamDataType
+	index int
+}
+
+// CsvReader reads datagrams from csv written by the recorder. Columns are found by the header, unknown ones like derived values
+// are skipped. Files written before the header was added, with all values of a field in one column, are read too.
+// Empty lines and lines starting with # are skipped.
+type CsvReader struct {
+	scanner *bufio.Scanner
+	line    int
+	pending string // First line of a legacy file, read to look for the header
+	legacy  bool
+	columns []csvColumn
+	fields  []StreamDataType
+	motors  int
+}
+
+func NewCsvReader(reader io.Reader) (*CsvReader, error) {
+	r := &CsvReader{scanner: bufio.NewScanner(reader)}
+	line, err := r.nextLine()
+	if errors.Is(err, io.EOF) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "session,") {
+		r.legacy = true
+		r.fields = legacyCsvFields
+		// Legacy header lists stream formats, not the columns
+		if first, _, _ := strings.Cut(line, ","); !slices.Contains(AllStreamFormatNames, first) {
+			r.pending = line
+		}
+		return r, nil
+	}
+	for _, name := range strings.Split(line, ",")[2:] {
+		column := csvColumn{field: Unknown}
+		if motor, ok := strings.CutPrefix(name, csvMotorRPMPrefix); ok {
+			if i, err := strconv.Atoi(motor); err == nil && i > 0 {
+				column = csvColumn{field: MotorRPM, index: i - 1}
+				r.motors = max(r.motors, i)
+			}
+		}
+		for field, names := range csvColumnNames {
+			if i := slices.Index(names, name); i >= 0 {
+				column = csvColumn{field: StreamDataType(field), index: i}
+			}
+		}
+		r.columns = append(r.columns, column)
+		if column.field != Unknown && !slices.Contains(r.fields, column.field) {
+			r.fields = append(r.fields, column.field)
+		}
+	}
+	return r, nil
+}
+
+// Fields returns fields found in the header
+func (r *CsvReader) Fields() []StreamDataType {
+	return r.fields
+}
+
+// Next reads the next line into the datagram and returns its session and event number. Returns io.EOF after the last one.
+// MotorRPM slice of the datagram is reused if it has enough capacity.
+func (r *CsvReader) Next(d *Datagram) (session int, event int, err error) {
+	line := r.pending
+	r.pending = ""
+	if line == "" {
+		if line, err = r.nextLine(); err != nil {
+			return 0, 0, err
+		}
+	}
+	values := strings.Split(line, ",")
+	if len(values) < 2 {
+		return 0, 0, fmt.Errorf("Line %d is invalid, expected session and event number: %s", r.line, line)
+	}
+	if session, err = strconv.Atoi(values[0]); err != nil {
+		return 0, 0, fmt.Errorf("Line %d is invalid, session is not a number: %s", r.line, values[0])
+	}
+	if event, err = strconv.Atoi(values[1]); err != nil {
+		return 0, 0, fmt.Errorf("Line %d is invalid, event is not a number: %s", r.line, values[1])
+	}
+	if r.legacy {
+		return session, event, r.parseLegacy(values[2:], d)
+	}
+	if len(values)-2 < len(r.columns) {
+		return 0, 0, fmt.Errorf("Line %d is invalid, expected %d values but found %d", r.line, len(r.columns)+2, len(values))
+	}
+	d.MotorRPM = resize(d.MotorRPM, r.motors)
+	for i, column := range r.columns {
+		if column.field == Unknown {
+			continue
+		}
+		v, err := strconv.ParseFloat(values[i+2], 32)
+		if err != nil {
+			return 0, 0, fmt.Errorf("Line %d is invalid, value of %s is not a valid float32: %s", r.line, column.field, values[i+2])
+		}
+		if column.field == Timestamp {
+			d.Timestamp = float32(v)
+		} else {
+			d.fieldValues(column.field)[column.index] = float32(v)
+		}
+	}
+	return session, event, nil
+}
+
+func (r *CsvReader) parseLegacy(values []string, d *Datagram) error {
+	if len(values) < len(legacyCsvFields) {
+		return fmt.Errorf("Line %d is invalid, expected at least %d values but found %d", r.line, len(legacyCsvFields)+2, len(values)+2)
+	}
+	timestamp, err := strconv.ParseFloat(values[0], 32)
+	if err != nil {
+		return fmt.Errorf("Line %d is invalid, timestamp is not a valid float32: %s", r.line, values[0])
+	}
+	d.Timestamp = float32(timestamp)
+	for i, field := range legacyCsvFields[1:] {
+		value := values[i+1]
+		parts := strings.Fields(strings.Trim(value, "[]"))
+		if field == MotorRPM {
+			d.MotorRPM = resize(d.MotorRPM, len(parts))
+		}
+		target := d.fieldValues(field)
+		if len(parts) != len(target) {
+			return fmt.Errorf("Line %d is invalid, expected %d values of %s: %s", r.line, len(target), field, value)
+		}
+		for j, part := range parts {
+			v, err := strconv.ParseFloat(part, 32)
+			if err != nil {
+				return fmt.Errorf("Line %d is invalid, value of %s is not a valid float32: %s", r.line, field, value)
+			}
+			target[j] = float32(v)
+		}
+	}
+	return nil
+}
+
+// nextLine returns the next line which is not empty or a comment
+func (r *CsvReader) nextLine() (string, error) {
+	for r.scanner.Scan() {
+		r.line++
+		line := strings.TrimSpace(r.scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			return line, nil
+		}
+	}
+	if err := r.scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", io.EOF
+}
+
+func resize(values []float32, n int) []float32 {
+	if cap(values) < n {
+		return make([]float32, n)
+	}
+	return values[:n]
+}
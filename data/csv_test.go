@@ -0,0 +1,113 @@
+package lot_config_test
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	lot_config "github.com/dladlk/liftoff-telemetry/data"
+)
+
+func TestCsvColumns(t *testing.T) {
+	tests := []struct {
+		name   string
+		fields []lot_config.StreamDataType
+		motors int
+		want   string
+	}{
+		{name: "Configured fields only", fields: []lot_config.StreamDataType{lot_config.Timestamp, lot_config.Input},
+			want: "session,event,timestamp,input_throttle,input_yaw,input_pitch,input_roll"},
+		{name: "Column per motor", fields: []lot_config.StreamDataType{lot_config.Timestamp, lot_config.Position, lot_config.MotorRPM, lot_config.Battery}, motors: 2,
+			want: "session,event,timestamp,pos_x,pos_y,pos_z,motor_rpm_1,motor_rpm_2,battery_v,battery_pct"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := strings.Join(lot_config.CsvColumns(tt.fields, tt.motors), ","); got != tt.want {
+				t.Errorf("CsvColumns() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCsvReader_RoundTrip(t *testing.T) {
+	fields := lot_config.ParseStreamDataTypeFormats(lot_config.AllStreamFormatNames)
+	datagrams := []lot_config.Datagram{
+		{Timestamp: 0.5, Position: [3]float32{1.5, 2, -3.25}, Attitude: [4]float32{0, 0, 0, 1}, Velocity: [3]float32{1e-7, 20, 0},
+			Gyro: [3]float32{360, -90, 0.1}, Input: [4]float32{-1, 0, 0.5, 1}, Battery: [2]float32{16.8, 1}, MotorRPM: []float32{1000, 2000, 3000, 4000}},
+		{Timestamp: 0.51, Position: [3]float32{1.6, 2, -3.25}, Battery: [2]float32{16.7, 0.99}, MotorRPM: []float32{1100, 2100, 3100, 4100}},
+	}
+
+	// Derived columns are skipped by the reader
+	var b strings.Builder
+	b.WriteString(strings.Join(lot_config.CsvColumns(fields, 4), ",") + ",speed\n")
+	for i := range datagrams {
+		b.Write(datagrams[i].AppendCsv(nil, 3, int32(i+1), fields, 4))
+		b.WriteString(",12.345\n")
+	}
+	if want := "\n3,1,0.5,1.5,2,-3.25,0,0,0,1,1e-07,20,0,360,-90,0.1,-1,0,0.5,1,16.8,1,1000,2000,3000,4000,12.345\n"; !strings.Contains(b.String(), want) {
+		t.Fatalf("Written csv\n%s\nhas no line %s", b.String(), want)
+	}
+
+	reader, err := lot_config.NewCsvReader(strings.NewReader(b.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(reader.Fields(), fields) {
+		t.Errorf("Fields() = %v, want %v", reader.Fields(), fields)
+	}
+	for i, want := range datagrams {
+		var got lot_config.Datagram
+		session, event, err := reader.Next(&got)
+		if err != nil {
+			t.Fatalf("Next() failed at line %d: %v", i+1, err)
+		}
+		if session != 3 || event != i+1 || !reflect.DeepEqual(got, want) {
+			t.Errorf("Next() = %d, %d, %+v, want 3, %d, %+v", session, event, got, i+1, want)
+		}
+	}
+	if _, _, err := reader.Next(&lot_config.Datagram{}); !errors.Is(err, io.EOF) {
+		t.Errorf("Next() after the last line = %v, want EOF", err)
+	}
+}
+
+// Excerpt of a csv written before the header was added, with all values of a field in one column
+const legacyCsv = `1,1,0,[15.752 0.034000017 -66.501],[0 0 0 1],[0 0 0],[0 0 0],[-1 0 0 -0],[0 0 0 0]
+1,101,0.99999934,[15.751977 1.799874e-05 -66.501],[2.5239142e-06 7.403759e-06 0.00055602915 0.9999998],[0.0006272526 -0.00039881468 -5.1567964e-05],[0.0008092626 -0.8397856 0.070741214],[0 0 0 -0],[0 0 0 0]
+1,1801,18.000317,[15.751998 9.0897083e-07 -66.501],[2.4299084e-08 6.3568996e-07 2.4449522e-05 1],[0.0002471888 -9.943545e-05 -2.060435e-06],[0.00029593866 -0.30305576 0.0045205024],[-0.41766995 0.0004882887 0.0004882887 -0.0024538843],[3068.5247 3047.6926 3064.228 3064.0793]
+`
+
+func TestCsvReader_Legacy(t *testing.T) {
+	// Legacy header with stream formats is skipped
+	reader, err := lot_config.NewCsvReader(strings.NewReader("Timestamp,Position,Attitude\n" + legacyCsv))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var d lot_config.Datagram
+	lines := 0
+	for {
+		_, _, err := reader.Next(&d)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines++
+		if lines == 1 && (d.Timestamp != 0 || d.Position != [3]float32{15.752, 0.034000017, -66.501} || d.Input[0] != -1 || len(d.MotorRPM) != 4) {
+			t.Errorf("First line = %+v", d)
+		}
+	}
+	if lines != 3 {
+		t.Errorf("Read %d lines, want 3", lines)
+	}
+
+	invalid, err := lot_config.NewCsvReader(strings.NewReader("1,1,0,[1 2],[0 0 0 1],[0 0 0],[0 0 0],[0 0 0 0],[]"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := invalid.Next(&d); err == nil {
+		t.Errorf("Next() of invalid position succeeded")
+	}
+}
@@ -29,51 +29,67 @@ func (d Datagram) DistanceFrom(firstEvent *Datagram) float64 {
 	return math.Sqrt(math.Pow(float64(a[0]-b[0]), 2) + math.Pow(float64(a[2]-b[2]), 2))
 }
 
+// CopyTo copies the datagram into dst, reusing MotorRPM slice of dst instead of sharing it
+func (d *Datagram) CopyTo(dst *Datagram) {
+	motorRPM := dst.MotorRPM
+	*dst = *d
+	dst.MotorRPM = append(motorRPM[:0], d.MotorRPM...)
+}
+
 func (d *Datagram) ZeroPosition() bool {
 	return d.Position[0] == 0 && d.Position[1] == 0 && d.Position[2] == 0
 }
 
+// ParseDatagram reads fields from the reader and stops the program if it fails. Use Read or Decode to handle errors.
 func (cur *Datagram) ParseDatagram(reader *bytes.Reader, fields *[]StreamDataType) {
+	if err := cur.Read(reader, *fields); err != nil {
+		log.Fatalf("Failed to parse datagram: %s\n", err)
+	}
+}
+
+// Decode parses the whole block, which must contain exactly the given fields
+func (cur *Datagram) Decode(block []byte, fields []StreamDataType) error {
+	reader := bytes.NewReader(block)
+	if err := cur.Read(reader, fields); err != nil {
+		return err
+	}
+	if reader.Len() > 0 {
+		return &DecodeError{Field: Unknown, Offset: len(block) - reader.Len(), Have: reader.Len(), Err: ErrMalformed}
+	}
+	return nil
+}
+
+// Read parses given fields from the reader, returns *DecodeError on failure
+func (cur *Datagram) Read(reader *bytes.Reader, fields []StreamDataType) error {
 	order := binary.LittleEndian
 
-	for _, dataType := range *fields {
+	for _, dataType := range fields {
+		offset := int(reader.Size()) - reader.Len()
+		var err error
 		switch dataType {
 		case Timestamp:
-			if err := binary.Read(reader, order, &cur.Timestamp); err != nil {
-				log.Fatalf("Failed to read Timestamp as float: %s\n", err)
-			}
+			err = binary.Read(reader, order, &cur.Timestamp)
 		case Position:
-			if err := binary.Read(reader, order, &cur.Position); err != nil {
-				log.Fatalf("Failed to read Position as float[3]: %s\n", err)
-			}
+			err = binary.Read(reader, order, &cur.Position)
 		case Attitude:
-			if err := binary.Read(reader, order, &cur.Attitude); err != nil {
-				log.Fatalf("Failed to read Attitude as float[4]: %s\n", err)
-			}
+			err = binary.Read(reader, order, &cur.Attitude)
 		case Velocity:
-			if err := binary.Read(reader, order, &cur.Velocity); err != nil {
-				log.Fatalf("Failed to read Velocity as float[3]: %s\n", err)
-			}
+			err = binary.Read(reader, order, &cur.Velocity)
 		case Gyro:
-			if err := binary.Read(reader, order, &cur.Gyro); err != nil {
-				log.Fatalf("Failed to read Gyro as float[3]: %s\n", err)
-			}
+			err = binary.Read(reader, order, &cur.Gyro)
 		case Input:
-			if err := binary.Read(reader, order, &cur.Input); err != nil {
-				log.Fatalf("Failed to read Input as float[4]: %s\n", err)
-			}
+			err = binary.Read(reader, order, &cur.Input)
 		case Battery:
-			if err := binary.Read(reader, order, &cur.Battery); err != nil {
-				log.Fatalf("Failed to read Battery as float[2]: %s\n", err)
-			}
+			err = binary.Read(reader, order, &cur.Battery)
 		case MotorRPM:
-			if err := binary.Read(reader, order, &cur.Motors); err != nil {
-				log.Fatalf("Failed to read Motors as byte: %s\n", err)
-			}
-			cur.MotorRPM = make([]float32, cur.Motors)
-			if err := binary.Read(reader, order, &cur.MotorRPM); err != nil {
-				log.Fatalf("Failed to read MotorRPM as float[%d]: %s\n", cur.Motors, err)
+			if err = binary.Read(reader, order, &cur.Motors); err == nil {
+				cur.MotorRPM = make([]float32, cur.Motors)
+				err = binary.Read(reader, order, &cur.MotorRPM)
 			}
 		}
+		if err != nil {
+			return &DecodeError{Field: dataType, Offset: offset, Length: FieldLength(dataType, int(cur.Motors)), Have: int(reader.Size()) - offset, Err: ErrTruncated}
+		}
 	}
+	return nil
 }
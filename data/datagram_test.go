@@ -0,0 +1,53 @@
+package lot_config_test
+
+import (
+	"errors"
+	"testing"
+
+	lot_config "github.com/dladlk/liftoff-telemetry/data"
+)
+
+func TestDatagram_Decode(t *testing.T) {
+	fields := []lot_config.StreamDataType{lot_config.Timestamp, lot_config.Position, lot_config.MotorRPM}
+	quad := make([]byte, lot_config.CalculateBlockLength(fields, 4))
+	quad[16] = 4
+
+	tests := []struct {
+		name      string
+		block     []byte
+		wantErr   error
+		wantField lot_config.StreamDataType
+		wantAt    int
+	}{
+		{name: "Valid", block: quad},
+		{name: "Empty", block: nil, wantErr: lot_config.ErrTruncated, wantField: lot_config.Timestamp, wantAt: 0},
+		{name: "Truncated position", block: quad[:10], wantErr: lot_config.ErrTruncated, wantField: lot_config.Position, wantAt: 4},
+		{name: "Truncated motors", block: quad[:20], wantErr: lot_config.ErrTruncated, wantField: lot_config.MotorRPM, wantAt: 16},
+		{name: "Trailing bytes", block: append(quad[:len(quad):len(quad)], 1, 2), wantErr: lot_config.ErrMalformed, wantField: lot_config.Unknown, wantAt: len(quad)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := lot_config.Datagram{}
+			err := d.Decode(tt.block, fields)
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatalf("Decode() failed: %v", err)
+				}
+				if len(d.MotorRPM) != 4 {
+					t.Errorf("Decode() read %d motors, want 4", len(d.MotorRPM))
+				}
+				return
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Decode() = %v, want %v", err, tt.wantErr)
+			}
+			var decodeErr *lot_config.DecodeError
+			if !errors.As(err, &decodeErr) {
+				t.Fatalf("Decode() error %T is not *DecodeError", err)
+			}
+			if decodeErr.Field != tt.wantField || decodeErr.Offset != tt.wantAt {
+				t.Errorf("Decode() failed on %s at %d, want %s at %d", decodeErr.Field, decodeErr.Offset, tt.wantField, tt.wantAt)
+			}
+		})
+	}
+}
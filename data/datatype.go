@@ -1,5 +1,10 @@
 package lot_config
 
+import (
+	"io"
+	"slices"
+)
+
 type StreamDataType int
 
 const (
@@ -14,32 +19,106 @@ const (
 	Unknown
 )
 
+var streamDataTypeNames = [...]string{"Timestamp", "Position", "Attitude", "Velocity", "Gyro", "Input", "Battery", "MotorRPM", "Unknown"}
+
+func (t StreamDataType) String() string {
+	if t < Timestamp || t > Unknown {
+		return streamDataTypeNames[Unknown]
+	}
+	return streamDataTypeNames[t]
+}
+
 const (
-	_float32 int8 = 4
-	_byte    int8 = 1
+	_float32 int = 4
+	_byte    int = 1
 )
 
-func CalculateBlockLength(fields []StreamDataType) int8 {
-	var length int8 = 0
+// Number of motors assumed when the real count is not known yet (quadcopter)
+const DefaultMotors = 4
+
+// FieldLength returns the number of bytes the field occupies in a block.
+// MotorRPM is the only field of variable length - 1 byte with motors count followed by float per motor.
+func FieldLength(field StreamDataType, motors int) int {
+	switch field {
+	case Timestamp:
+		return _float32
+	case Position, Velocity, Gyro:
+		return 3 * _float32
+	case Attitude, Input:
+		return 4 * _float32
+	case Battery:
+		return 2 * _float32
+	case MotorRPM:
+		return _byte + motors*_float32
+	}
+	return 0
+}
+
+// CalculateBlockLength returns block length for given fields and the number of motors
+func CalculateBlockLength(fields []StreamDataType, motors int) int {
+	length := 0
 	for _, field := range fields {
-		var fieldLength int8
-		switch field {
-		case Timestamp:
-			fieldLength = _float32
-		case Position, Velocity, Gyro:
-			fieldLength = 3 * _float32
-		case Attitude, Input:
-			fieldLength = 4 * _float32
-		case Battery:
-			fieldLength = 2 * _float32
-		case MotorRPM:
-			fieldLength = _byte + 4*_float32
-		}
-		length += fieldLength
+		length += FieldLength(field, motors)
 	}
 	return length
 }
 
+// MinBlockLength returns the shortest possible block length for given fields - when MotorRPM reports no motors
+func MinBlockLength(fields []StreamDataType) int {
+	return CalculateBlockLength(fields, 0)
+}
+
+// BlockLength returns the real length of the block by reading the motors count from the block itself.
+// Returns false if the block is too short to contain the motors count.
+func BlockLength(fields []StreamDataType, block []byte) (int, bool) {
+	length := 0
+	for _, field := range fields {
+		if field == MotorRPM {
+			if length >= len(block) {
+				return 0, false
+			}
+			length += FieldLength(field, int(block[length]))
+		} else {
+			length += FieldLength(field, 0)
+		}
+	}
+	return length, true
+}
+
+// ReadBlock reads exactly one block from the reader into buf, growing it if needed.
+// Returns io.EOF if the reader is exhausted before the block started and *DecodeError with ErrTruncated if it ended in the middle of block.
+func ReadBlock(reader io.Reader, fields []StreamDataType, buf []byte) ([]byte, error) {
+	buf = buf[:0]
+	for _, field := range fields {
+		offset := len(buf)
+		length := FieldLength(field, 0)
+		if field == MotorRPM {
+			var motors [1]byte
+			if n, err := io.ReadFull(reader, motors[:]); err != nil {
+				return buf, readBlockError(err, field, offset, length, n)
+			}
+			buf = append(buf, motors[0])
+			length = FieldLength(field, int(motors[0]))
+		}
+		start := len(buf)
+		buf = slices.Grow(buf, offset+length-start)[:offset+length]
+		if n, err := io.ReadFull(reader, buf[start:]); err != nil {
+			return buf[:start+n], readBlockError(err, field, offset, length, start-offset+n)
+		}
+	}
+	return buf, nil
+}
+
+func readBlockError(err error, field StreamDataType, offset int, length int, have int) error {
+	if err == io.EOF && offset == 0 {
+		return io.EOF
+	}
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return &DecodeError{Field: field, Offset: offset, Length: length, Have: have, Err: ErrTruncated}
+	}
+	return err
+}
+
 func ParseStreamDataTypeFormats(formatNames []string) []StreamDataType {
 	streamFormats := make([]StreamDataType, len(formatNames))
 	for i, name := range formatNames {
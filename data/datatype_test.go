@@ -0,0 +1,56 @@
+package lot_config_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	lot_config "github.com/dladlk/liftoff-telemetry/data"
+)
+
+func TestBlockLength(t *testing.T) {
+	fields := []lot_config.StreamDataType{lot_config.Timestamp, lot_config.MotorRPM, lot_config.Battery}
+
+	tests := []struct {
+		name   string
+		motors byte
+		want   int
+	}{
+		{name: "No motors", motors: 0, want: 4 + 1 + 8},
+		{name: "Quad", motors: 4, want: 4 + 1 + 16 + 8},
+		{name: "Hexa", motors: 6, want: 4 + 1 + 24 + 8},
+		{name: "Octo", motors: 8, want: 4 + 1 + 32 + 8},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			block := make([]byte, tt.want)
+			block[4] = tt.motors
+			got, ok := lot_config.BlockLength(fields, block)
+			if !ok || got != tt.want {
+				t.Errorf("BlockLength() = %d, %v, want %d", got, ok, tt.want)
+			}
+			if calculated := lot_config.CalculateBlockLength(fields, int(tt.motors)); calculated != tt.want {
+				t.Errorf("CalculateBlockLength() = %d, want %d", calculated, tt.want)
+			}
+
+			read, err := lot_config.ReadBlock(bytes.NewReader(append(block, block...)), fields, nil)
+			if err != nil || len(read) != tt.want {
+				t.Errorf("ReadBlock() = %d bytes, %v, want %d", len(read), err, tt.want)
+			}
+			if _, err := lot_config.ReadBlock(bytes.NewReader(block[:tt.want-1]), fields, nil); !errors.Is(err, lot_config.ErrTruncated) {
+				t.Errorf("ReadBlock() on truncated block = %v, want %v", err, lot_config.ErrTruncated)
+			}
+			if _, err := lot_config.ReadBlock(bytes.NewReader(nil), fields, nil); err != io.EOF {
+				t.Errorf("ReadBlock() on empty input = %v, want %v", err, io.EOF)
+			}
+		})
+	}
+
+	if _, ok := lot_config.BlockLength(fields, make([]byte, 4)); ok {
+		t.Errorf("BlockLength() succeeded on block without motors count")
+	}
+	if got := lot_config.MinBlockLength(fields); got != 13 {
+		t.Errorf("MinBlockLength() = %d, want %d", got, 13)
+	}
+}
@@ -0,0 +1,77 @@
+package lot_config
+
+import (
+	"encoding/binary"
+	"math"
+	"slices"
+)
+
+// Decoder is built once for the configured stream formats and decodes blocks without reflection.
+// Decoding into a reused Datagram does not allocate.
+type Decoder struct {
+	fields    []StreamDataType
+	minLength int
+}
+
+func NewDecoder(fields []StreamDataType) *Decoder {
+	return &Decoder{fields: slices.Clone(fields), minLength: MinBlockLength(fields)}
+}
+
+func (d *Decoder) Fields() []StreamDataType {
+	return d.fields
+}
+
+func (d *Decoder) MinBlockLength() int {
+	return d.minLength
+}
+
+// Decode parses the whole block into cur, which is owned by the caller and can be reused between calls.
+// MotorRPM slice of cur is reused if it has enough capacity.
+func (d *Decoder) Decode(block []byte, cur *Datagram) error {
+	offset := 0
+	for _, field := range d.fields {
+		length := FieldLength(field, 0)
+		if field == MotorRPM && offset < len(block) {
+			length = FieldLength(field, int(block[offset]))
+		}
+		if offset+length > len(block) {
+			return &DecodeError{Field: field, Offset: offset, Length: length, Have: len(block) - offset, Err: ErrTruncated}
+		}
+		b := block[offset : offset+length]
+		switch field {
+		case Timestamp:
+			cur.Timestamp = readFloat32(b)
+		case Position:
+			readFloat32s(b, cur.Position[:])
+		case Attitude:
+			readFloat32s(b, cur.Attitude[:])
+		case Velocity:
+			readFloat32s(b, cur.Velocity[:])
+		case Gyro:
+			readFloat32s(b, cur.Gyro[:])
+		case Input:
+			readFloat32s(b, cur.Input[:])
+		case Battery:
+			readFloat32s(b, cur.Battery[:])
+		case MotorRPM:
+			cur.Motors = b[0]
+			cur.MotorRPM = slices.Grow(cur.MotorRPM[:0], int(cur.Motors))[:cur.Motors]
+			readFloat32s(b[_byte:], cur.MotorRPM)
+		}
+		offset += length
+	}
+	if offset < len(block) {
+		return &DecodeError{Field: Unknown, Offset: offset, Have: len(block) - offset, Err: ErrMalformed}
+	}
+	return nil
+}
+
+func readFloat32(b []byte) float32 {
+	return math.Float32frombits(binary.LittleEndian.Uint32(b))
+}
+
+func readFloat32s(b []byte, dst []float32) {
+	for i := range dst {
+		dst[i] = readFloat32(b[i*_float32:])
+	}
+}
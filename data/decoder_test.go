@@ -0,0 +1,99 @@
+package lot_config_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"slices"
+	"testing"
+
+	lot_config "github.com/dladlk/liftoff-telemetry/data"
+)
+
+var allFields = []lot_config.StreamDataType{
+	lot_config.Timestamp, lot_config.Position, lot_config.Attitude, lot_config.Velocity,
+	lot_config.Gyro, lot_config.Input, lot_config.Battery, lot_config.MotorRPM,
+}
+
+// sampleBlock builds a block for all fields with distinct values and given number of motors
+func sampleBlock(motors int) []byte {
+	buf := new(bytes.Buffer)
+	floats := func(n int) {
+		for i := 0; i < n; i++ {
+			binary.Write(buf, binary.LittleEndian, float32(buf.Len())+0.5)
+		}
+	}
+	floats(1 + 3 + 4 + 3 + 3 + 4 + 2)
+	buf.WriteByte(byte(motors))
+	floats(motors)
+	return buf.Bytes()
+}
+
+// sameDatagram compares datagrams treating nil and empty MotorRPM as equal
+func sameDatagram(a, b lot_config.Datagram) bool {
+	if !slices.Equal(a.MotorRPM, b.MotorRPM) {
+		return false
+	}
+	a.MotorRPM, b.MotorRPM = nil, nil
+	return reflect.DeepEqual(a, b)
+}
+
+func TestDecoder_Decode(t *testing.T) {
+	for _, motors := range []int{0, 4, 6, 8} {
+		block := sampleBlock(motors)
+
+		want := lot_config.Datagram{}
+		if err := want.Decode(block, allFields); err != nil {
+			t.Fatalf("Datagram.Decode() failed: %v", err)
+		}
+
+		got := lot_config.Datagram{}
+		decoder := lot_config.NewDecoder(allFields)
+		if err := decoder.Decode(block, &got); err != nil {
+			t.Fatalf("Decoder.Decode() failed: %v", err)
+		}
+		if !sameDatagram(got, want) {
+			t.Errorf("Decoder.Decode() = %+v, want %+v", got, want)
+		}
+
+		for n := range block {
+			if err := decoder.Decode(block[:n], &got); err == nil {
+				t.Errorf("Decoder.Decode() succeeded on %d of %d bytes", n, len(block))
+			}
+		}
+	}
+}
+
+func TestDecoder_DecodeNoAllocs(t *testing.T) {
+	block := sampleBlock(8)
+	decoder := lot_config.NewDecoder(allFields)
+	cur := lot_config.Datagram{}
+	allocs := testing.AllocsPerRun(100, func() {
+		if err := decoder.Decode(block, &cur); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("Decoder.Decode() allocates %.1f times per call", allocs)
+	}
+}
+
+func BenchmarkDecoder_Decode(b *testing.B) {
+	block := sampleBlock(4)
+	decoder := lot_config.NewDecoder(allFields)
+	cur := lot_config.Datagram{}
+	b.ReportAllocs()
+	for b.Loop() {
+		decoder.Decode(block, &cur)
+	}
+}
+
+func BenchmarkDatagram_ParseDatagram(b *testing.B) {
+	block := sampleBlock(4)
+	fields := allFields
+	b.ReportAllocs()
+	for b.Loop() {
+		cur := lot_config.Datagram{}
+		cur.ParseDatagram(bytes.NewReader(block), &fields)
+	}
+}
@@ -0,0 +1,85 @@
+package lot_config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Environment variable with the path to TelemetryConfiguration.json, overrides auto-discovery
+const TelemetryConfigEnv = "LIFTOFF_TELEMETRY_CONFIG"
+
+// Steam application id of Liftoff, used to find its Proton prefix
+const liftoffSteamAppId = "410340"
+
+const telemetryConfigName = "TelemetryConfiguration.json"
+
+var liftoffLocalLow = filepath.Join("AppData", "LocalLow", "LuGus Studios", "Liftoff")
+
+// ConfigNotFoundError lists all paths where TelemetryConfiguration.json was looked for
+type ConfigNotFoundError struct {
+	Candidates []string
+}
+
+func (e *ConfigNotFoundError) Error() string {
+	if len(e.Candidates) == 0 {
+		return fmt.Sprintf("%s not found: no candidate paths, set %s env variable", telemetryConfigName, TelemetryConfigEnv)
+	}
+	return fmt.Sprintf("%s not found, checked:\n\t%s", telemetryConfigName, strings.Join(e.Candidates, "\n\t"))
+}
+
+// TelemetryConfigCandidates returns paths to look for TelemetryConfiguration.json in order of priority.
+// Explicit path, if not empty, is the only candidate. Otherwise env variable, native Windows path and Steam Proton/Wine prefixes on Linux are used.
+func TelemetryConfigCandidates(explicitPath string) []string {
+	if explicitPath != "" {
+		return []string{explicitPath}
+	}
+	if envPath := os.Getenv(TelemetryConfigEnv); envPath != "" {
+		return []string{envPath}
+	}
+
+	var candidates []string
+	if userProfile := os.Getenv("USERPROFILE"); userProfile != "" {
+		candidates = append(candidates, filepath.Join(userProfile, liftoffLocalLow, telemetryConfigName))
+	}
+	if winePrefix := os.Getenv("WINEPREFIX"); winePrefix != "" {
+		candidates = append(candidates, wineUserPaths(winePrefix)...)
+	}
+	if home, err := os.UserHomeDir(); err == nil && home != "" {
+		for _, steamRoot := range []string{
+			filepath.Join(home, ".steam", "steam"),
+			filepath.Join(home, ".steam", "root"),
+			filepath.Join(home, ".local", "share", "Steam"),
+			filepath.Join(home, ".var", "app", "com.valvesoftware.Steam", ".local", "share", "Steam"),
+		} {
+			candidates = append(candidates, wineUserPaths(filepath.Join(steamRoot, "steamapps", "compatdata", liftoffSteamAppId, "pfx"))...)
+		}
+		candidates = append(candidates, wineUserPaths(filepath.Join(home, ".wine"))...)
+	}
+	return candidates
+}
+
+// Proton always runs games as steamuser, plain Wine uses the name of current user
+func wineUserPaths(prefix string) []string {
+	users := []string{"steamuser"}
+	if user := os.Getenv("USER"); user != "" && user != "steamuser" {
+		users = append(users, user)
+	}
+	paths := make([]string, len(users))
+	for i, user := range users {
+		paths[i] = filepath.Join(prefix, "drive_c", "users", user, liftoffLocalLow, telemetryConfigName)
+	}
+	return paths
+}
+
+// FindTelemetryConfig returns the first existing candidate path or *ConfigNotFoundError with all checked paths
+func FindTelemetryConfig(explicitPath string) (string, error) {
+	candidates := TelemetryConfigCandidates(explicitPath)
+	for _, candidate := range candidates {
+		if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+			return candidate, nil
+		}
+	}
+	return "", &ConfigNotFoundError{Candidates: candidates}
+}
@@ -0,0 +1,56 @@
+package lot_config_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	lot_config "github.com/dladlk/liftoff-telemetry/data"
+)
+
+func TestFindTelemetryConfig(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", "")
+	t.Setenv("WINEPREFIX", "")
+	t.Setenv("USER", "pilot")
+	t.Setenv(lot_config.TelemetryConfigEnv, "")
+
+	_, err := lot_config.FindTelemetryConfig("")
+	var notFound *lot_config.ConfigNotFoundError
+	if !errors.As(err, &notFound) {
+		t.Fatalf("FindTelemetryConfig() = %v, want *ConfigNotFoundError", err)
+	}
+	proton := filepath.Join(home, ".local", "share", "Steam", "steamapps", "compatdata", "410340", "pfx",
+		"drive_c", "users", "steamuser", "AppData", "LocalLow", "LuGus Studios", "Liftoff", "TelemetryConfiguration.json")
+	if !slices.Contains(notFound.Candidates, proton) {
+		t.Errorf("Candidates %v do not contain Proton path %s", notFound.Candidates, proton)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(proton), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(proton, []byte(`{"EndPoint": "127.0.0.1:9001", "StreamFormat": ["Timestamp", "Position"]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := lot_config.FindTelemetryConfig("")
+	if err != nil || got != proton {
+		t.Errorf("FindTelemetryConfig() = %s, %v, want %s", got, err, proton)
+	}
+
+	config, err := lot_config.ReadLiftoffTelemetryConfig()
+	if err != nil {
+		t.Fatalf("ReadLiftoffTelemetryConfig() failed: %v", err)
+	}
+	if config.Endpoint != "127.0.0.1:9001" || !config.HasPosition() || config.Path != proton {
+		t.Errorf("ReadLiftoffTelemetryConfig() = %+v", config)
+	}
+
+	explicit := filepath.Join(home, "missing.json")
+	t.Setenv(lot_config.TelemetryConfigEnv, proton)
+	if _, err := lot_config.FindTelemetryConfig(explicit); !errors.As(err, &notFound) || !slices.Equal(notFound.Candidates, []string{explicit}) {
+		t.Errorf("FindTelemetryConfig(%s) = %v, want only explicit path checked", explicit, err)
+	}
+}
@@ -0,0 +1,49 @@
+package lot_config
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// Encode returns the datagram in Liftoff wire layout for given fields, symmetric to Decode
+func (d *Datagram) Encode(fields []StreamDataType) []byte {
+	return d.AppendEncoded(make([]byte, 0, CalculateBlockLength(fields, len(d.MotorRPM))), fields)
+}
+
+// AppendEncoded appends the datagram in Liftoff wire layout to buf, so the caller can reuse buffer between calls.
+// Motors count is taken from the length of MotorRPM.
+func (d *Datagram) AppendEncoded(buf []byte, fields []StreamDataType) []byte {
+	for _, field := range fields {
+		switch field {
+		case Timestamp:
+			buf = appendFloat32(buf, d.Timestamp)
+		case Position:
+			buf = appendFloat32s(buf, d.Position[:])
+		case Attitude:
+			buf = appendFloat32s(buf, d.Attitude[:])
+		case Velocity:
+			buf = appendFloat32s(buf, d.Velocity[:])
+		case Gyro:
+			buf = appendFloat32s(buf, d.Gyro[:])
+		case Input:
+			buf = appendFloat32s(buf, d.Input[:])
+		case Battery:
+			buf = appendFloat32s(buf, d.Battery[:])
+		case MotorRPM:
+			buf = append(buf, byte(len(d.MotorRPM)))
+			buf = appendFloat32s(buf, d.MotorRPM)
+		}
+	}
+	return buf
+}
+
+func appendFloat32(buf []byte, v float32) []byte {
+	return binary.LittleEndian.AppendUint32(buf, math.Float32bits(v))
+}
+
+func appendFloat32s(buf []byte, values []float32) []byte {
+	for _, v := range values {
+		buf = appendFloat32(buf, v)
+	}
+	return buf
+}
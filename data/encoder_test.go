@@ -0,0 +1,95 @@
+package lot_config_test
+
+import (
+	"math/rand"
+	"testing"
+
+	lot_config "github.com/dladlk/liftoff-telemetry/data"
+)
+
+func randomDatagram(r *rand.Rand) lot_config.Datagram {
+	values := func(dst []float32) {
+		for i := range dst {
+			dst[i] = float32(r.NormFloat64() * 1000)
+		}
+	}
+	d := lot_config.Datagram{Timestamp: r.Float32() * 1000}
+	values(d.Position[:])
+	values(d.Attitude[:])
+	values(d.Velocity[:])
+	values(d.Gyro[:])
+	values(d.Input[:])
+	values(d.Battery[:])
+	d.Motors = byte(r.Intn(9))
+	d.MotorRPM = make([]float32, d.Motors)
+	values(d.MotorRPM)
+	return d
+}
+
+// onlyFields returns the datagram with all fields not present in the list set to zero
+func onlyFields(d lot_config.Datagram, fields []lot_config.StreamDataType) lot_config.Datagram {
+	res := lot_config.Datagram{}
+	for _, field := range fields {
+		switch field {
+		case lot_config.Timestamp:
+			res.Timestamp = d.Timestamp
+		case lot_config.Position:
+			res.Position = d.Position
+		case lot_config.Attitude:
+			res.Attitude = d.Attitude
+		case lot_config.Velocity:
+			res.Velocity = d.Velocity
+		case lot_config.Gyro:
+			res.Gyro = d.Gyro
+		case lot_config.Input:
+			res.Input = d.Input
+		case lot_config.Battery:
+			res.Battery = d.Battery
+		case lot_config.MotorRPM:
+			res.Motors = d.Motors
+			res.MotorRPM = d.MotorRPM
+		}
+	}
+	return res
+}
+
+func TestDatagram_EncodeRoundTrip(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+
+	// Every subset of fields, each in a random order
+	for mask := 0; mask < 1<<len(allFields); mask++ {
+		var fields []lot_config.StreamDataType
+		for i, field := range allFields {
+			if mask&(1<<i) != 0 {
+				fields = append(fields, field)
+			}
+		}
+		r.Shuffle(len(fields), func(i, j int) { fields[i], fields[j] = fields[j], fields[i] })
+		decoder := lot_config.NewDecoder(fields)
+
+		for range 10 {
+			d := randomDatagram(r)
+			block := d.Encode(fields)
+
+			if want := lot_config.CalculateBlockLength(fields, len(d.MotorRPM)); len(block) != want {
+				t.Fatalf("%v: Encode() length = %d, want %d", fields, len(block), want)
+			}
+
+			got := lot_config.Datagram{}
+			if err := decoder.Decode(block, &got); err != nil {
+				t.Fatalf("%v: Decode() failed: %v", fields, err)
+			}
+			if want := onlyFields(d, fields); !sameDatagram(got, want) {
+				t.Fatalf("%v: round trip = %+v, want %+v", fields, got, want)
+			}
+
+			slow := lot_config.Datagram{}
+			if err := slow.Decode(block, fields); err != nil {
+				t.Fatalf("%v: Datagram.Decode() failed: %v", fields, err)
+			}
+			if !sameDatagram(slow, got) {
+				t.Fatalf("%v: Datagram.Decode() = %+v, want %+v", fields, slow, got)
+			}
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package lot_config
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// Input ended before all configured fields were read
+	ErrTruncated = errors.New("truncated input")
+	// Input does not match configured fields - e.g. has trailing bytes
+	ErrMalformed = errors.New("malformed input")
+)
+
+// DecodeError describes which field failed to decode and where
+type DecodeError struct {
+	Field  StreamDataType // Unknown if error is not related to a single field
+	Offset int            // Byte offset of the field in the block
+	Length int            // Number of bytes the field needs
+	Have   int            // Number of bytes available at offset
+	Err    error          // ErrTruncated or ErrMalformed
+}
+
+func (e *DecodeError) Error() string {
+	if e.Field == Unknown {
+		return fmt.Sprintf("%v at offset %d: expected block of %d bytes, but got %d", e.Err, e.Offset, e.Offset+e.Length, e.Offset+e.Have)
+	}
+	return fmt.Sprintf("%v: failed to read %s at offset %d, need %d bytes, have %d", e.Err, e.Field, e.Offset, e.Length, e.Have)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+func (e *DecodeError) Truncated() bool {
+	return errors.Is(e.Err, ErrTruncated)
+}
@@ -0,0 +1,197 @@
+package lot_config
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// DefaultSpeedThresholds in meters/second, time above each of them is counted
+var DefaultSpeedThresholds = []float64{10, 20, 30}
+
+const (
+	// Throttle input, -1 to 1, from which throttle is full
+	fullThrottle = 0.95
+	// Punch-out is full throttle reached within this time in seconds after throttle below half
+	punchOutTime = 0.5
+	// Gap in seconds over which time is not counted, e.g. pause or restart
+	maxFlightStatsGap = 1.0
+)
+
+// FlightStats accumulate time weighted stats of datagrams of a trip. Stats of fields which are not sent stay zero.
+type FlightStats struct {
+	Time               float64   `json:"time"`     // Game time counted for averages, without gaps, seconds
+	AvgSpeed           float64   `json:"avgSpeed"` // 3d, meters/second
+	MinAltitude        float64   `json:"minAltitude"`
+	MaxAltitude        float64   `json:"maxAltitude"`
+	SpeedThresholds    []float64 `json:"speedThresholds,omitempty"`
+	TimeAbove          []float64 `json:"timeAbove,omitempty"`    // Seconds above each of speed thresholds
+	ThrottleMean       float64   `json:"throttleMean,omitempty"` // 0 to 1
+	FullThrottle       float64   `json:"fullThrottle,omitempty"` // Seconds
+	PunchOuts          int       `json:"punchOuts,omitempty"`
+	BatteryVoltageUsed float64   `json:"batteryVoltageUsed,omitempty"`
+	BatteryChargeUsed  float64   `json:"batteryChargeUsed,omitempty"`
+	AvgRPM             float64   `json:"avgRpm,omitempty"` // Of all motors
+	PeakRPM            float64   `json:"peakRpm,omitempty"`
+	Rotation           float64   `json:"rotation,omitempty"` // Total angle rotated by gyro rates, degrees
+
+	hasPosition bool
+	hasInput    bool
+	hasBattery  bool
+	hasMotorRPM bool
+	hasGyro     bool
+	newPosition bool // Field is sent since the last datagram, its first value is taken as a start one
+	newBattery  bool
+
+	count       int
+	prevTs      float32
+	speedSum    float64 // Sums of values multiplied by time
+	throttleSum float64
+	rpmSum      float64
+	full        bool
+	low         bool    // Throttle was below half and no punch-out is counted since then
+	lowTs       float32 // Last timestamp of throttle below half
+	battery     [2]float32
+}
+
+func NewFlightStats(fields []StreamDataType, speedThresholds []float64) FlightStats {
+	s := FlightStats{SpeedThresholds: speedThresholds, TimeAbove: make([]float64, len(speedThresholds))}
+	s.setFields(fields)
+	return s
+}
+
+// SetFields changes fields the datagrams are sent with, e.g. when telemetry config is reloaded.
+// Stats collected so far are kept, those of newly sent fields start from the next datagram.
+func (s *FlightStats) SetFields(fields []StreamDataType) {
+	hadPosition, hadBattery := s.hasPosition, s.hasBattery
+	s.setFields(fields)
+	s.newPosition = s.hasPosition && !hadPosition && s.count > 0
+	s.newBattery = s.hasBattery && !hadBattery && s.count > 0
+}
+
+func (s *FlightStats) setFields(fields []StreamDataType) {
+	s.hasPosition, s.hasInput, s.hasBattery, s.hasMotorRPM, s.hasGyro = false, false, false, false, false
+	for _, field := range fields {
+		switch field {
+		case Position:
+			s.hasPosition = true
+		case Input:
+			s.hasInput = true
+		case Battery:
+			s.hasBattery = true
+		case MotorRPM:
+			s.hasMotorRPM = true
+		case Gyro:
+			s.hasGyro = true
+		}
+	}
+}
+
+// Update adds the datagram with its 3d speed. Values of each datagram last until the next one.
+func (s *FlightStats) Update(d *Datagram, speed float64) {
+	var dt float64
+	if s.count > 0 {
+		if delta := float64(d.Timestamp - s.prevTs); delta > 0 && delta <= maxFlightStatsGap {
+			dt = delta
+		}
+	} else {
+		s.battery = d.Battery
+		s.MinAltitude = float64(d.Position[1])
+		s.MaxAltitude = s.MinAltitude
+	}
+	if s.newPosition {
+		s.MinAltitude = float64(d.Position[1])
+		s.MaxAltitude = s.MinAltitude
+		s.newPosition = false
+	}
+	if s.newBattery {
+		s.battery = d.Battery
+		s.newBattery = false
+	}
+	s.count++
+	s.prevTs = d.Timestamp
+	s.Time += dt
+
+	s.speedSum += speed * dt
+	for i, threshold := range s.SpeedThresholds {
+		if speed > threshold {
+			s.TimeAbove[i] += dt
+		}
+	}
+	if s.hasPosition {
+		s.MinAltitude = math.Min(s.MinAltitude, float64(d.Position[1]))
+		s.MaxAltitude = math.Max(s.MaxAltitude, float64(d.Position[1]))
+	}
+	if s.hasInput {
+		s.updateThrottle(d, dt)
+	}
+	if s.hasBattery {
+		s.BatteryVoltageUsed = float64(s.battery[0] - d.Battery[0])
+		s.BatteryChargeUsed = float64(s.battery[1] - d.Battery[1])
+	}
+	if s.hasMotorRPM && len(d.MotorRPM) > 0 {
+		var sum float64
+		for _, rpm := range d.MotorRPM {
+			sum += float64(rpm)
+			s.PeakRPM = math.Max(s.PeakRPM, float64(rpm))
+		}
+		s.rpmSum += sum / float64(len(d.MotorRPM)) * dt
+	}
+	if s.hasGyro {
+		g := d.Gyro
+		s.Rotation += math.Sqrt(float64(g[0]*g[0]+g[1]*g[1]+g[2]*g[2])) * dt
+	}
+
+	if s.Time > 0 {
+		s.AvgSpeed = s.speedSum / s.Time
+		s.ThrottleMean = s.throttleSum / s.Time
+		s.AvgRPM = s.rpmSum / s.Time
+	}
+}
+
+func (s *FlightStats) updateThrottle(d *Datagram, dt float64) {
+	throttle := float64(d.Input[0])
+	s.throttleSum += (throttle + 1) / 2 * dt
+	full := throttle >= fullThrottle
+	if full {
+		s.FullThrottle += dt
+		if !s.full && s.low && d.Timestamp-s.lowTs <= punchOutTime {
+			s.PunchOuts++
+			s.low = false
+		}
+	}
+	if throttle < 0 {
+		s.low = true
+		s.lowTs = d.Timestamp
+	}
+	s.full = full
+}
+
+func (s FlightStats) String() string {
+	parts := []string{fmt.Sprintf("avg speed %.2f m/s", s.AvgSpeed)}
+	if s.hasPosition {
+		parts = append(parts, fmt.Sprintf("altitude %.1f..%.1f", s.MinAltitude, s.MaxAltitude))
+	}
+	if len(s.SpeedThresholds) > 0 {
+		thresholds := make([]string, len(s.SpeedThresholds))
+		times := make([]string, len(s.TimeAbove))
+		for i, threshold := range s.SpeedThresholds {
+			thresholds[i] = fmt.Sprintf("%g", threshold)
+			times[i] = fmt.Sprintf("%.1f", s.TimeAbove[i])
+		}
+		parts = append(parts, fmt.Sprintf("above %s m/s %s s", strings.Join(thresholds, "/"), strings.Join(times, "/")))
+	}
+	if s.hasInput {
+		parts = append(parts, fmt.Sprintf("throttle %.0f%%, full %.1f s, %d punch-outs", s.ThrottleMean*100, s.FullThrottle, s.PunchOuts))
+	}
+	if s.hasBattery {
+		parts = append(parts, fmt.Sprintf("battery used %.2f V, %.3f charge", s.BatteryVoltageUsed, s.BatteryChargeUsed))
+	}
+	if s.hasMotorRPM {
+		parts = append(parts, fmt.Sprintf("rpm avg %.0f, peak %.0f", s.AvgRPM, s.PeakRPM))
+	}
+	if s.hasGyro {
+		parts = append(parts, fmt.Sprintf("rotation %.1f turns", s.Rotation/360))
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,85 @@
+package lot_config_test
+
+import (
+	"math"
+	"testing"
+
+	lot_config "github.com/dladlk/liftoff-telemetry/data"
+)
+
+func TestFlightStats_Update(t *testing.T) {
+	fields := []lot_config.StreamDataType{lot_config.Timestamp, lot_config.Position, lot_config.Gyro, lot_config.Input, lot_config.Battery, lot_config.MotorRPM}
+	s := lot_config.NewFlightStats(fields, []float64{10, 20})
+
+	// 10 s at 0.1 s steps: hover at low throttle for 2 s, punch out to 25 m/s for 1 s, then cruise at 15 m/s and half throttle,
+	// rolling at 360 degrees/second for the last 2 s
+	for i := 0; i <= 100; i++ {
+		ts := float64(i) * 0.1
+		d := lot_config.Datagram{
+			Timestamp: float32(ts),
+			Position:  [3]float32{0, float32(5 + i/10), 0},
+			Battery:   [2]float32{float32(16.8 - ts*0.1), float32(1 - ts*0.05)},
+			MotorRPM:  []float32{1000, 3000},
+		}
+		speed := 15.0
+		switch {
+		case ts <= 2:
+			speed = 0
+			d.Input[0] = -0.5
+		case ts <= 3:
+			speed = 25
+			d.Input[0] = 1
+			d.MotorRPM = []float32{20000, 22000}
+		}
+		if ts > 8 {
+			d.Gyro = [3]float32{0, 360, 0}
+		}
+		s.Update(&d, speed)
+	}
+
+	approx := func(name string, got, want float64) {
+		t.Helper()
+		if math.Abs(got-want) > 1e-3 {
+			t.Errorf("%s = %v, want %v", name, got, want)
+		}
+	}
+	approx("Time", s.Time, 10)
+	approx("AvgSpeed", s.AvgSpeed, (1*25+7*15)/10.0)
+	approx("MinAltitude", s.MinAltitude, 5)
+	approx("MaxAltitude", s.MaxAltitude, 15)
+	approx("TimeAbove[0]", s.TimeAbove[0], 8)
+	approx("TimeAbove[1]", s.TimeAbove[1], 1)
+	approx("ThrottleMean", s.ThrottleMean, (2*0.25+1*1+7*0.5)/10)
+	approx("FullThrottle", s.FullThrottle, 1)
+	approx("BatteryVoltageUsed", s.BatteryVoltageUsed, 1)
+	approx("BatteryChargeUsed", s.BatteryChargeUsed, 0.5)
+	approx("AvgRPM", s.AvgRPM, (1*21000+9*2000)/10.0)
+	approx("PeakRPM", s.PeakRPM, 22000)
+	approx("Rotation", s.Rotation, 720)
+	if s.PunchOuts != 1 {
+		t.Errorf("PunchOuts = %d, want 1", s.PunchOuts)
+	}
+}
+
+func TestFlightStats_Gap(t *testing.T) {
+	s := lot_config.NewFlightStats([]lot_config.StreamDataType{lot_config.Timestamp, lot_config.Position}, nil)
+	// Restart drops timestamp back and a pause makes it jump, neither is counted as flight time
+	for _, ts := range []float32{0, 0.5, 1, 0.2, 0.4, 30, 30.5} {
+		s.Update(&lot_config.Datagram{Timestamp: ts}, 10)
+	}
+	if math.Abs(s.Time-1.7) > 1e-6 || math.Abs(s.AvgSpeed-10) > 1e-6 {
+		t.Errorf("Time, AvgSpeed = %v, %v, want 1.7, 10", s.Time, s.AvgSpeed)
+	}
+}
+
+func TestFlightStats_SetFields(t *testing.T) {
+	s := lot_config.NewFlightStats([]lot_config.StreamDataType{lot_config.Timestamp}, nil)
+	s.Update(&lot_config.Datagram{Timestamp: 0, Position: [3]float32{0, 50, 0}, Battery: [2]float32{16.8, 1}}, 10)
+	// Battery and position are sent after config reload, their first values are the start ones
+	s.SetFields([]lot_config.StreamDataType{lot_config.Timestamp, lot_config.Position, lot_config.Battery})
+	s.Update(&lot_config.Datagram{Timestamp: 1, Position: [3]float32{0, 10, 0}, Battery: [2]float32{16, 0.9}}, 10)
+	s.Update(&lot_config.Datagram{Timestamp: 2, Position: [3]float32{0, 12, 0}, Battery: [2]float32{15.5, 0.8}}, 10)
+	if s.MinAltitude != 10 || s.MaxAltitude != 12 || math.Abs(s.BatteryVoltageUsed-0.5) > 1e-6 || math.Abs(s.BatteryChargeUsed-0.1) > 1e-6 || s.Time != 2 {
+		t.Errorf("Stats after SetFields = %+v", s)
+	}
+}
@@ -0,0 +1,124 @@
+package lot_config
+
+import "math"
+
+// Liftoff reports data in Unity frame: left-handed, Y is up, Z is forward and X is right.
+// Angles below are converted to pilot signs: positive pitch is nose up, positive roll is right wing down,
+// heading is clockwise from world +Z in range [0, 360).
+
+// EulerAngles in degrees
+type EulerAngles struct {
+	Roll    float64
+	Pitch   float64
+	Heading float64
+}
+
+// BodyVelocity is velocity in the drone frame, meters/second
+type BodyVelocity struct {
+	Forward  float64
+	Lateral  float64 `desc:"positive to the right"`
+	Vertical float64 `desc:"positive along drone up axis"`
+}
+
+type quaternion struct {
+	x, y, z, w float64
+}
+
+// newQuaternion normalizes the attitude, zero attitude (not sent by Liftoff) is treated as no rotation
+func newQuaternion(attitude [4]float32) quaternion {
+	q := quaternion{float64(attitude[0]), float64(attitude[1]), float64(attitude[2]), float64(attitude[3])}
+	norm := math.Sqrt(q.x*q.x + q.y*q.y + q.z*q.z + q.w*q.w)
+	if norm == 0 {
+		return quaternion{w: 1}
+	}
+	return quaternion{q.x / norm, q.y / norm, q.z / norm, q.w / norm}
+}
+
+// Axes of the drone in world space - columns of the rotation matrix
+func (q quaternion) right() [3]float64 {
+	return [3]float64{1 - 2*(q.y*q.y+q.z*q.z), 2 * (q.x*q.y + q.z*q.w), 2 * (q.x*q.z - q.y*q.w)}
+}
+
+func (q quaternion) up() [3]float64 {
+	return [3]float64{2 * (q.x*q.y - q.z*q.w), 1 - 2*(q.x*q.x+q.z*q.z), 2 * (q.y*q.z + q.x*q.w)}
+}
+
+func (q quaternion) forward() [3]float64 {
+	return [3]float64{2 * (q.x*q.z + q.y*q.w), 2 * (q.y*q.z - q.x*q.w), 1 - 2*(q.x*q.x+q.y*q.y)}
+}
+
+func dot(a [3]float64, b [3]float32) float64 {
+	return a[0]*float64(b[0]) + a[1]*float64(b[1]) + a[2]*float64(b[2])
+}
+
+func degrees(radians float64) float64 {
+	return radians * 180 / math.Pi
+}
+
+// EulerAngles converts Attitude the same way as Unity does (Z, X, Y order) with pilot signs
+func (d *Datagram) EulerAngles() EulerAngles {
+	q := newQuaternion(d.Attitude)
+	forward := q.forward()
+	right := q.right()
+	return EulerAngles{
+		Roll:    -degrees(math.Atan2(right[1], q.up()[1])),
+		Pitch:   degrees(math.Asin(math.Max(-1, math.Min(1, forward[1])))),
+		Heading: d.Heading(),
+	}
+}
+
+// Heading is compass direction of the drone nose projected to horizontal plane, degrees clockwise from world +Z
+func (d *Datagram) Heading() float64 {
+	forward := newQuaternion(d.Attitude).forward()
+	heading := degrees(math.Atan2(forward[0], forward[2]))
+	if heading < 0 {
+		heading += 360
+	}
+	return heading
+}
+
+// Tilt is the angle between drone up axis and vertical in degrees, 0 is level and 180 is upside down
+func (d *Datagram) Tilt() float64 {
+	up := newQuaternion(d.Attitude).up()
+	return degrees(math.Acos(math.Max(-1, math.Min(1, up[1]))))
+}
+
+func (d *Datagram) UpsideDown() bool {
+	return newQuaternion(d.Attitude).up()[1] < 0
+}
+
+// BodyVelocity rotates world space Velocity into the drone frame
+func (d *Datagram) BodyVelocity() BodyVelocity {
+	q := newQuaternion(d.Attitude)
+	return BodyVelocity{
+		Forward:  dot(q.forward(), d.Velocity),
+		Lateral:  dot(q.right(), d.Velocity),
+		Vertical: dot(q.up(), d.Velocity),
+	}
+}
+
+// Slerp interpolates attitude between a (t=0) and b (t=1) along the shortest arc
+func Slerp(a [4]float32, b [4]float32, t float64) [4]float32 {
+	qa := newQuaternion(a)
+	qb := newQuaternion(b)
+	cos := qa.x*qb.x + qa.y*qb.y + qa.z*qb.z + qa.w*qb.w
+	if cos < 0 {
+		// q and -q are the same rotation, take the shorter way
+		cos = -cos
+		qb = quaternion{-qb.x, -qb.y, -qb.z, -qb.w}
+	}
+	wa, wb := 1-t, t
+	if cos < 0.9995 {
+		angle := math.Acos(cos)
+		sin := math.Sin(angle)
+		wa = math.Sin((1-t)*angle) / sin
+		wb = math.Sin(t*angle) / sin
+	}
+	res := newQuaternion([4]float32{
+		float32(wa*qa.x + wb*qb.x),
+		float32(wa*qa.y + wb*qb.y),
+		float32(wa*qa.z + wb*qb.z),
+		float32(wa*qa.w + wb*qb.w),
+	})
+	return [4]float32{float32(res.x), float32(res.y), float32(res.z), float32(res.w)}
+}
@@ -0,0 +1,86 @@
+package lot_config_test
+
+import (
+	"math"
+	"testing"
+
+	lot_config "github.com/dladlk/liftoff-telemetry/data"
+)
+
+// axisAngle builds Unity quaternion for rotation by degrees around axis
+func axisAngle(axis [3]float64, degrees float64) [4]float32 {
+	half := degrees * math.Pi / 360
+	s := math.Sin(half)
+	return [4]float32{float32(axis[0] * s), float32(axis[1] * s), float32(axis[2] * s), float32(math.Cos(half))}
+}
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-3
+}
+
+// nearAngle compares angles in degrees, so -180 and 180 are equal
+func nearAngle(a, b float64) bool {
+	return near(math.Mod(a-b+540, 360), 180)
+}
+
+func TestDatagram_EulerAngles(t *testing.T) {
+	tests := []struct {
+		name       string
+		attitude   [4]float32
+		want       lot_config.EulerAngles
+		wantTilt   float64
+		velocity   [3]float32
+		wantBody   lot_config.BodyVelocity
+		wantUpside bool
+	}{
+		{name: "Level", attitude: [4]float32{0, 0, 0, 1}, velocity: [3]float32{0, 0, 10}, wantBody: lot_config.BodyVelocity{Forward: 10}},
+		{name: "Not sent", attitude: [4]float32{}, velocity: [3]float32{0, 2, 0}, wantBody: lot_config.BodyVelocity{Vertical: 2}},
+		{name: "Turned right", attitude: axisAngle([3]float64{0, 1, 0}, 90), want: lot_config.EulerAngles{Heading: 90},
+			velocity: [3]float32{10, 0, 0}, wantBody: lot_config.BodyVelocity{Forward: 10}},
+		{name: "Turned left", attitude: axisAngle([3]float64{0, 1, 0}, -90), want: lot_config.EulerAngles{Heading: 270},
+			velocity: [3]float32{10, 0, 0}, wantBody: lot_config.BodyVelocity{Forward: -10}},
+		{name: "Nose up", attitude: axisAngle([3]float64{1, 0, 0}, -30), want: lot_config.EulerAngles{Pitch: 30}, wantTilt: 30,
+			velocity: [3]float32{0, 0, 10}, wantBody: lot_config.BodyVelocity{Forward: 10 * math.Cos(math.Pi/6), Vertical: -10 * math.Sin(math.Pi/6)}},
+		{name: "Rolled right", attitude: axisAngle([3]float64{0, 0, 1}, -45), want: lot_config.EulerAngles{Roll: 45}, wantTilt: 45,
+			velocity: [3]float32{10, 0, 0}, wantBody: lot_config.BodyVelocity{Lateral: 10 * math.Cos(math.Pi/4), Vertical: 10 * math.Sin(math.Pi/4)}},
+		{name: "Upside down", attitude: axisAngle([3]float64{0, 0, 1}, 180), want: lot_config.EulerAngles{Roll: 180}, wantTilt: 180, wantUpside: true,
+			velocity: [3]float32{0, 5, 0}, wantBody: lot_config.BodyVelocity{Vertical: -5}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := lot_config.Datagram{Attitude: tt.attitude, Velocity: tt.velocity}
+			got := d.EulerAngles()
+			if !nearAngle(got.Roll, tt.want.Roll) || !near(got.Pitch, tt.want.Pitch) || !nearAngle(got.Heading, tt.want.Heading) {
+				t.Errorf("EulerAngles() = %+v, want %+v", got, tt.want)
+			}
+			if tilt := d.Tilt(); !near(tilt, tt.wantTilt) {
+				t.Errorf("Tilt() = %.3f, want %.3f", tilt, tt.wantTilt)
+			}
+			if upside := d.UpsideDown(); upside != tt.wantUpside {
+				t.Errorf("UpsideDown() = %v, want %v", upside, tt.wantUpside)
+			}
+			body := d.BodyVelocity()
+			if !near(body.Forward, tt.wantBody.Forward) || !near(body.Lateral, tt.wantBody.Lateral) || !near(body.Vertical, tt.wantBody.Vertical) {
+				t.Errorf("BodyVelocity() = %+v, want %+v", body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestSlerp(t *testing.T) {
+	a := axisAngle([3]float64{0, 1, 0}, 10)
+	b := axisAngle([3]float64{0, 1, 0}, 90)
+	for _, step := range []float64{0, 0.25, 0.5, 1} {
+		d := lot_config.Datagram{Attitude: lot_config.Slerp(a, b, step)}
+		if want := 10 + 80*step; !near(d.Heading(), want) {
+			t.Errorf("Slerp(%.2f) heading = %.3f, want %.3f", step, d.Heading(), want)
+		}
+	}
+
+	// Negated quaternion is the same rotation, slerp must not go the long way around
+	negated := [4]float32{-b[0], -b[1], -b[2], -b[3]}
+	d := lot_config.Datagram{Attitude: lot_config.Slerp(a, negated, 0.5)}
+	if !near(d.Heading(), 50) {
+		t.Errorf("Slerp() to negated quaternion heading = %.3f, want 50", d.Heading())
+	}
+}
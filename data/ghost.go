@@ -0,0 +1,139 @@
+package lot_config
+
+import "math"
+
+// TracePoint is a position of the drone at the time since the start of the lap
+type TracePoint struct {
+	Time     float64    `json:"t"`
+	Position [3]float32 `json:"p"`
+}
+
+const (
+	// DefaultGhostSegments is the number of equal parts of the best lap time deltas are kept for
+	DefaultGhostSegments = 10
+	// DefaultTraceInterval is the minimal time in seconds between recorded trace points
+	DefaultTraceInterval = 0.05
+	// Nearest point is searched this many trace segments back and ahead of the last one, the whole trace is searched if it is farther
+	ghostWindowBack  = 5
+	ghostWindowAhead = 100
+	ghostMaxDistance = 20.0
+)
+
+// Ghost compares the current lap with the trace of the best one at the same point of the track
+type Ghost struct {
+	trace    []TracePoint
+	segments int
+	index    int
+	next     int // Next segment to pass, starting with 0
+	deltas   []float64
+}
+
+func NewGhost(trace []TracePoint, segments int) *Ghost {
+	return &Ghost{trace: trace, segments: segments}
+}
+
+// Duration returns time of the ghost lap
+func (g *Ghost) Duration() float64 {
+	if len(g.trace) == 0 {
+		return 0
+	}
+	return g.trace[len(g.trace)-1].Time
+}
+
+// Reset starts comparing a new lap
+func (g *Ghost) Reset() {
+	g.index = 0
+	g.next = 0
+	g.deltas = nil
+}
+
+// Update projects the position onto the ghost trace and returns delta of lap time to the ghost time at that point,
+// positive if the drone is behind the ghost. Segment is the number of the segment passed by this update starting with 1, 0 if none.
+func (g *Ghost) Update(position [3]float32, lapTime float64) (delta float64, segment int, ok bool) {
+	if len(g.trace) < 2 {
+		return 0, 0, false
+	}
+	p := vec(position)
+	index, u, distance := g.nearest(p, max(0, g.index-ghostWindowBack), min(len(g.trace)-1, g.index+ghostWindowAhead))
+	if distance > ghostMaxDistance {
+		// Lost in the window, e.g. after a crash or a short-cut
+		index, u, distance = g.nearest(p, 0, len(g.trace)-1)
+		if distance > ghostMaxDistance {
+			return 0, 0, false
+		}
+	}
+	g.index = index
+	a, b := g.trace[index], g.trace[index+1]
+	ghostTime := a.Time + u*(b.Time-a.Time)
+	delta = lapTime - ghostTime
+
+	// Segments are passed in order, jumps back of the nearest point do not pass them again
+	for g.next < g.segments && ghostTime >= g.Duration()*float64(g.next+1)/float64(g.segments) {
+		g.deltas = append(g.deltas, delta)
+		g.next++
+		segment = g.next
+	}
+	return delta, segment, true
+}
+
+// Finish passes the remaining segments at the end of the lap with the final delta and returns deltas of all segments
+func (g *Ghost) Finish(lapTime float64) []float64 {
+	delta := lapTime - g.Duration()
+	for g.next < g.segments {
+		g.deltas = append(g.deltas, delta)
+		g.next++
+	}
+	return g.deltas
+}
+
+// SegmentDeltas returns deltas at the ends of passed segments of the current lap
+func (g *Ghost) SegmentDeltas() []float64 {
+	return g.deltas
+}
+
+// nearest finds trace segment from..to nearest to p, returns its index, position along it from 0 to 1 and distance
+func (g *Ghost) nearest(p [3]float64, from, to int) (int, float64, float64) {
+	best, bestU, bestDistance := from, 0.0, math.Inf(1)
+	for i := from; i < to; i++ {
+		a, b := vec(g.trace[i].Position), vec(g.trace[i+1].Position)
+		ab := sub(b, a)
+		u := 0.0
+		if length := dot3(ab, ab); length > 0 {
+			u = math.Max(0, math.Min(1, dot3(sub(p, a), ab)/length))
+		}
+		q := [3]float64{a[0] + u*ab[0], a[1] + u*ab[1], a[2] + u*ab[2]}
+		d := sub(p, q)
+		if distance := math.Sqrt(dot3(d, d)); distance < bestDistance {
+			best, bestU, bestDistance = i, u, distance
+		}
+	}
+	return best, bestU, bestDistance
+}
+
+// TraceRecorder collects positions of the current lap with limited frequency
+type TraceRecorder struct {
+	interval float64
+	trace    []TracePoint
+}
+
+func NewTraceRecorder(interval float64) *TraceRecorder {
+	return &TraceRecorder{interval: interval}
+}
+
+// Reset starts a new lap trace, the previous one is kept by whom it was returned to
+func (t *TraceRecorder) Reset() {
+	t.trace = nil
+}
+
+func (t *TraceRecorder) Add(position [3]float32, lapTime float64) {
+	if n := len(t.trace); n > 0 && lapTime-t.trace[n-1].Time < t.interval {
+		return
+	}
+	t.trace = append(t.trace, TracePoint{Time: lapTime, Position: position})
+}
+
+// Finish adds the final point at the lap time and returns the trace
+func (t *TraceRecorder) Finish(position [3]float32, lapTime float64) []TracePoint {
+	t.trace = append(t.trace, TracePoint{Time: lapTime, Position: position})
+	return t.trace
+}
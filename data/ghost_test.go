@@ -0,0 +1,69 @@
+package lot_config_test
+
+import (
+	"math"
+	"testing"
+
+	lot_config "github.com/dladlk/liftoff-telemetry/data"
+)
+
+func TestGhost(t *testing.T) {
+	// Best lap goes 100 m forward at 10 m/s and returns 5 m aside
+	recorder := lot_config.NewTraceRecorder(lot_config.DefaultTraceInterval)
+	for i := 0; i < 200; i++ {
+		lapTime := float64(i) * 0.1
+		if i < 100 {
+			recorder.Add([3]float32{0, 1, float32(i)}, lapTime)
+		} else {
+			recorder.Add([3]float32{5, 1, float32(200 - i)}, lapTime)
+		}
+	}
+	trace := recorder.Finish([3]float32{5, 1, 0}, 20)
+	if len(trace) != 201 {
+		t.Fatalf("Trace has %d points, want 201", len(trace))
+	}
+
+	ghost := lot_config.NewGhost(trace, 4)
+	if ghost.Duration() != 20 {
+		t.Errorf("Duration() = %v, want 20", ghost.Duration())
+	}
+
+	tests := []struct {
+		name        string
+		position    [3]float32
+		lapTime     float64
+		wantOk      bool
+		wantDelta   float64
+		wantSegment int
+	}{
+		{name: "Start", position: [3]float32{0, 1, 0}, lapTime: 0, wantOk: true},
+		{name: "Ahead", position: [3]float32{0.5, 1, 40}, lapTime: 3, wantOk: true, wantDelta: -1},
+		{name: "First segment passed", position: [3]float32{0, 1, 60}, lapTime: 5, wantOk: true, wantDelta: -1, wantSegment: 1},
+		{name: "Lost", position: [3]float32{50, 1, 60}, lapTime: 5.5},
+		{name: "Return path is not taken for the near forward one", position: [3]float32{1, 1, 80}, lapTime: 7, wantOk: true, wantDelta: -1},
+		{name: "Behind on return", position: [3]float32{5, 1, 90}, lapTime: 12, wantOk: true, wantDelta: 1, wantSegment: 2},
+		{name: "Finish", position: [3]float32{5, 1, 0}, lapTime: 19, wantOk: true, wantDelta: -1, wantSegment: 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			delta, segment, ok := ghost.Update(tt.position, tt.lapTime)
+			if ok != tt.wantOk || ok && (math.Abs(delta-tt.wantDelta) > 1e-3 || segment != tt.wantSegment) {
+				t.Errorf("Update() = %v, %v, %v, want %v, %v, %v", delta, segment, ok, tt.wantDelta, tt.wantSegment, tt.wantOk)
+			}
+		})
+	}
+	if deltas := ghost.SegmentDeltas(); len(deltas) != 4 || math.Abs(deltas[0]+1) > 1e-3 || math.Abs(deltas[1]-1) > 1e-3 {
+		t.Errorf("SegmentDeltas() = %v, want 4 deltas starting with -1, 1", deltas)
+	}
+
+	ghost.Reset()
+	if len(ghost.SegmentDeltas()) != 0 {
+		t.Errorf("SegmentDeltas() after Reset() = %v", ghost.SegmentDeltas())
+	}
+
+	// Lap finished before the last segments are passed gets the final delta for them
+	ghost.Update([3]float32{0, 1, 60}, 5)
+	if deltas := ghost.Finish(21.5); len(deltas) != 4 || math.Abs(deltas[0]+1) > 1e-3 || math.Abs(deltas[3]-1.5) > 1e-3 {
+		t.Errorf("Finish() = %v, want 4 deltas starting with -1 and ending with 1.5", deltas)
+	}
+}
@@ -0,0 +1,190 @@
+package lot_config
+
+import (
+	"fmt"
+	"math"
+	"slices"
+)
+
+type HealthEventKind int
+
+const (
+	HealthOk HealthEventKind = iota
+	// Timestamp jumped forward more than expected - packets were lost
+	HealthGap
+	// Timestamp is slightly lower than already seen - packet came out of order and should be ignored
+	HealthReordered
+	// Timestamp did not change
+	HealthFrozen
+	// Timestamp did not change for long enough to consider the game paused or in menu
+	HealthPaused
+	// Timestamp is growing again after pause
+	HealthResumed
+	// Timestamp dropped far back - race is restarted
+	HealthRestarted
+)
+
+var healthEventNames = [...]string{"ok", "gap", "reordered", "frozen", "paused", "resumed", "restarted"}
+
+func (k HealthEventKind) String() string {
+	if int(k) < len(healthEventNames) {
+		return healthEventNames[k]
+	}
+	return "unknown"
+}
+
+type HealthEvent struct {
+	Kind  HealthEventKind
+	Delta float64 // Timestamp change in seconds
+	Lost  int     // Estimated number of lost packets for HealthGap
+}
+
+func (e HealthEvent) String() string {
+	if e.Kind == HealthGap {
+		return fmt.Sprintf("%s of %.3f s, ~%d packets lost", e.Kind, e.Delta, e.Lost)
+	}
+	return fmt.Sprintf("%s by %.3f s", e.Kind, e.Delta)
+}
+
+// HealthStats are counters of stream problems since the last reset
+type HealthStats struct {
+	Packets         int     `json:"packets"`
+	Gaps            int     `json:"gaps"`
+	Lost            int     `json:"lost"`
+	Reordered       int     `json:"reordered"`
+	Frozen          int     `json:"frozen"`
+	Pauses          int     `json:"pauses"`
+	Restarts        int     `json:"restarts"`
+	NominalInterval float64 `json:"nominalInterval"` // Learned send interval in seconds
+	Jitter          float64 `json:"jitter"`          // Mean absolute deviation of interval from nominal in seconds
+}
+
+func (s HealthStats) String() string {
+	return fmt.Sprintf("%d packets, interval %.1f ms, jitter %.2f ms, %d gaps with ~%d lost, %d reordered, %d frozen, %d pauses, %d restarts",
+		s.Packets, s.NominalInterval*1000, s.Jitter*1000, s.Gaps, s.Lost, s.Reordered, s.Frozen, s.Pauses, s.Restarts)
+}
+
+const (
+	healthWarmup = 16
+	// Interval bigger than nominal by this ratio is a gap
+	healthGapRatio = 1.8
+	// Consecutive packets with the same timestamp to consider the game paused
+	healthPauseFrozen = 10
+	// Backward jump up to this many nominal intervals is reordering, bigger one is restart
+	healthReorderIntervals = 10
+	// Reorder window in seconds while nominal interval is not learned yet
+	healthDefaultReorder = 0.1
+	// Maximal reorder window in seconds, so slow streams do not take an early restart of the race for late packets
+	healthMaxReorder = 0.5
+	// Consecutive growing timestamps behind the last one which are a restart close to it, not late packets
+	healthRestartBehind = 5
+	healthSmoothing     = 0.05
+)
+
+// HealthAnalyzer learns nominal send interval from Timestamp and classifies deviations from it
+type HealthAnalyzer struct {
+	started bool
+	last    float32 // Highest timestamp seen since the last restart
+	frozen  int
+	paused  bool
+	warmup  []float64
+	stats   HealthStats
+	behind  int     // Consecutive growing timestamps behind the last one
+	prevTs  float32 // Previous timestamp, even if it is behind
+}
+
+func NewHealthAnalyzer() *HealthAnalyzer {
+	return &HealthAnalyzer{}
+}
+
+// Stats returns counters, nominal interval and jitter
+func (a *HealthAnalyzer) Stats() HealthStats {
+	return a.stats
+}
+
+// ResetStats clears counters, e.g. for the next session, keeping learned interval
+func (a *HealthAnalyzer) ResetStats() {
+	a.stats = HealthStats{NominalInterval: a.stats.NominalInterval, Jitter: a.stats.Jitter}
+}
+
+func (a *HealthAnalyzer) Update(timestamp float32) HealthEvent {
+	a.stats.Packets++
+	if !a.started {
+		a.started = true
+		a.last = timestamp
+		return HealthEvent{Kind: HealthOk}
+	}
+	delta := float64(timestamp - a.last)
+	nominal := a.stats.NominalInterval
+	if delta >= 0 {
+		a.behind = 0
+	} else if a.behind > 0 && timestamp > a.prevTs {
+		a.behind++
+	} else {
+		a.behind = 1
+	}
+	a.prevTs = timestamp
+
+	switch {
+	case delta == 0:
+		a.stats.Frozen++
+		a.frozen++
+		if a.frozen == healthPauseFrozen {
+			a.paused = true
+			a.stats.Pauses++
+			return HealthEvent{Kind: HealthPaused}
+		}
+		return HealthEvent{Kind: HealthFrozen}
+	case delta < 0:
+		window := healthDefaultReorder
+		if nominal > 0 {
+			window = min(nominal*healthReorderIntervals, healthMaxReorder)
+		}
+		if -delta <= window && a.behind < healthRestartBehind {
+			a.stats.Reordered++
+			return HealthEvent{Kind: HealthReordered, Delta: delta}
+		}
+		if a.behind >= healthRestartBehind {
+			// Stream goes on from a timestamp a bit lower than the last one, previous packets behind were not late ones
+			a.stats.Reordered -= a.behind - 1
+		}
+		a.behind = 0
+		a.stats.Restarts++
+		a.last = timestamp
+		a.frozen = 0
+		a.paused = false
+		return HealthEvent{Kind: HealthRestarted, Delta: delta}
+	}
+
+	a.last = timestamp
+	a.frozen = 0
+	if a.paused {
+		a.paused = false
+		return HealthEvent{Kind: HealthResumed, Delta: delta}
+	}
+
+	if nominal == 0 {
+		a.learn(delta)
+		return HealthEvent{Kind: HealthOk, Delta: delta}
+	}
+	if delta > nominal*healthGapRatio {
+		lost := int(math.Round(delta/nominal)) - 1
+		a.stats.Gaps++
+		a.stats.Lost += lost
+		return HealthEvent{Kind: HealthGap, Delta: delta, Lost: lost}
+	}
+	a.stats.NominalInterval += healthSmoothing * (delta - nominal)
+	a.stats.Jitter += healthSmoothing * (math.Abs(delta-nominal) - a.stats.Jitter)
+	return HealthEvent{Kind: HealthOk, Delta: delta}
+}
+
+// learn takes median of the first intervals as nominal, so early gaps do not skew it
+func (a *HealthAnalyzer) learn(delta float64) {
+	a.warmup = append(a.warmup, delta)
+	if len(a.warmup) < healthWarmup {
+		return
+	}
+	slices.Sort(a.warmup)
+	a.stats.NominalInterval = a.warmup[len(a.warmup)/2]
+	a.warmup = nil
+}
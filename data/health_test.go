@@ -0,0 +1,93 @@
+package lot_config_test
+
+import (
+	"math"
+	"testing"
+
+	lot_config "github.com/dladlk/liftoff-telemetry/data"
+)
+
+// steady returns n timestamps starting at from with 10 ms interval
+func steady(from float32, n int) []float32 {
+	res := make([]float32, n)
+	for i := range res {
+		res[i] = from + float32(i)*0.01
+	}
+	return res
+}
+
+func repeat(ts float32, n int) []float32 {
+	res := make([]float32, n)
+	for i := range res {
+		res[i] = ts
+	}
+	return res
+}
+
+func join(parts ...[]float32) []float32 {
+	var res []float32
+	for _, part := range parts {
+		res = append(res, part...)
+	}
+	return res
+}
+
+func TestHealthAnalyzer_Update(t *testing.T) {
+	tests := []struct {
+		name       string
+		timestamps []float32
+		wantLast   lot_config.HealthEventKind
+		want       lot_config.HealthStats
+	}{
+		{name: "Steady", timestamps: steady(0, 50), wantLast: lot_config.HealthOk,
+			want: lot_config.HealthStats{Packets: 50}},
+		{name: "Gap", timestamps: join(steady(0, 30), steady(0.34, 1)), wantLast: lot_config.HealthGap,
+			want: lot_config.HealthStats{Packets: 31, Gaps: 1, Lost: 4}},
+		{name: "Reordered", timestamps: join(steady(0, 30), []float32{0.28}), wantLast: lot_config.HealthReordered,
+			want: lot_config.HealthStats{Packets: 31, Reordered: 1}},
+		{name: "Restarted", timestamps: join(steady(10, 30), steady(0, 1)), wantLast: lot_config.HealthRestarted,
+			want: lot_config.HealthStats{Packets: 31, Restarts: 1}},
+		{name: "Restarted close to the last timestamp", timestamps: join(steady(0, 30), steady(0.2, 5)), wantLast: lot_config.HealthRestarted,
+			want: lot_config.HealthStats{Packets: 35, Restarts: 1}},
+		{name: "Late packets", timestamps: join(steady(0, 30), steady(0.25, 4), steady(0.3, 1)), wantLast: lot_config.HealthOk,
+			want: lot_config.HealthStats{Packets: 35, Reordered: 4}},
+		{name: "Paused", timestamps: join(steady(0, 30), repeat(0.29, 10)), wantLast: lot_config.HealthPaused,
+			want: lot_config.HealthStats{Packets: 40, Frozen: 10, Pauses: 1}},
+		{name: "Resumed", timestamps: join(steady(0, 30), repeat(0.29, 20), steady(0.30, 1)), wantLast: lot_config.HealthResumed,
+			want: lot_config.HealthStats{Packets: 51, Frozen: 20, Pauses: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := lot_config.NewHealthAnalyzer()
+			var last lot_config.HealthEvent
+			for _, ts := range tt.timestamps {
+				last = a.Update(ts)
+			}
+			if last.Kind != tt.wantLast {
+				t.Errorf("Update() last = %v, want %v", last, tt.wantLast)
+			}
+			got := a.Stats()
+			if math.Abs(got.NominalInterval-0.01) > 0.0005 {
+				t.Errorf("NominalInterval = %.4f, want 0.01", got.NominalInterval)
+			}
+			got.NominalInterval, got.Jitter = 0, 0
+			if got != tt.want {
+				t.Errorf("Stats() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHealthAnalyzer_SlowStream(t *testing.T) {
+	a := lot_config.NewHealthAnalyzer()
+	for i := 0; i < 20; i++ {
+		a.Update(float32(i) * 0.1)
+	}
+	// Ten intervals of 100 ms stream are more than restart of the race after a second
+	if event := a.Update(1.2); event.Kind != lot_config.HealthRestarted {
+		t.Errorf("Update() of jump back by 0.7 s = %v, want restarted", event)
+	}
+	if event := a.Update(1.25); event.Kind != lot_config.HealthOk {
+		t.Errorf("Update() after restart = %v, want ok", event)
+	}
+}
@@ -0,0 +1,131 @@
+package lot_config
+
+import "math"
+
+// Standard gravity, m/s²
+const Gravity = 9.80665
+
+// Kinematics are channels derived from consecutive datagrams
+type Kinematics struct {
+	Speed           float64 `desc:"3d speed, meters/second"`
+	HorizontalSpeed float64 `desc:"speed in X, Z plane, meters/second"`
+	VerticalSpeed   float64 `desc:"positive up, meters/second"`
+	Altitude        float64 `desc:"Position Y, meters"`
+	Acceleration    float64 `desc:"filtered acceleration magnitude, meters/second²"`
+	GLoad           float64 `desc:"filtered proper acceleration in g, 1 when hovering"`
+	HasAcceleration bool    `desc:"false until there are enough datagrams with growing timestamp"`
+	HasVelocity     bool    `desc:"false if Velocity is not sent and can not be derived from Position change yet"`
+	Interval        float64 `desc:"time since previous datagram, seconds, 0 after a gap, pause or restart"`
+	VelocityChange  float64 `desc:"unfiltered velocity change since previous datagram, meters/second, 0 if it is not known"`
+}
+
+// KinematicsCalculator derives Kinematics from consecutive datagrams, they are computed once per datagram and shared by detectors.
+// Velocity is taken from Velocity field or, if it is not sent, from Position change.
+// Acceleration is velocity change over Timestamp delta smoothed by exponential moving average.
+type KinematicsCalculator struct {
+	hasVelocity  bool
+	hasPosition  bool
+	hasTimestamp bool
+	smoothing    float64
+
+	prev         bool
+	prevTs       float32
+	prevPosition [3]float32
+	prevVelocity [3]float64
+	prevValid    bool
+	acceleration [3]float64
+	hasAccel     bool
+}
+
+// Default time constant of acceleration filter in seconds - Liftoff physics noise is mostly above 10 Hz
+const DefaultKinematicsSmoothing = 0.1
+
+// Gap in seconds after which previous datagram is not used to derive acceleration
+const maxKinematicsGap = 0.5
+
+func NewKinematicsCalculator(fields []StreamDataType, smoothing float64) *KinematicsCalculator {
+	c := &KinematicsCalculator{smoothing: smoothing}
+	for _, field := range fields {
+		switch field {
+		case Velocity:
+			c.hasVelocity = true
+		case Position:
+			c.hasPosition = true
+		case Timestamp:
+			c.hasTimestamp = true
+		}
+	}
+	return c
+}
+
+// Reset forgets previous datagrams, e.g. when race is restarted
+func (c *KinematicsCalculator) Reset() {
+	c.prev = false
+	c.prevValid = false
+	c.hasAccel = false
+	c.acceleration = [3]float64{}
+}
+
+func (c *KinematicsCalculator) Update(d *Datagram) Kinematics {
+	dt := float64(d.Timestamp - c.prevTs)
+	connected := c.prev && c.hasTimestamp && dt > 0 && dt <= maxKinematicsGap
+
+	var velocity [3]float64
+	valid := false
+	switch {
+	case c.hasVelocity:
+		velocity = [3]float64{float64(d.Velocity[0]), float64(d.Velocity[1]), float64(d.Velocity[2])}
+		valid = true
+	case c.hasPosition && connected:
+		for i := range velocity {
+			velocity[i] = float64(d.Position[i]-c.prevPosition[i]) / dt
+		}
+		valid = true
+	case c.hasPosition && c.prev:
+		// No time delta to derive velocity from, keep the last one
+		velocity = c.prevVelocity
+	}
+
+	k := Kinematics{
+		HorizontalSpeed: math.Hypot(velocity[0], velocity[2]),
+		VerticalSpeed:   velocity[1],
+		Altitude:        float64(d.Position[1]),
+		HasVelocity:     valid,
+	}
+	k.Speed = math.Hypot(k.HorizontalSpeed, velocity[1])
+	if connected {
+		k.Interval = dt
+	}
+
+	if connected && valid && c.prevValid {
+		k.VelocityChange = math.Sqrt(sq(velocity[0]-c.prevVelocity[0]) + sq(velocity[1]-c.prevVelocity[1]) + sq(velocity[2]-c.prevVelocity[2]))
+		alpha := 1.0
+		if c.smoothing > 0 && c.hasAccel {
+			alpha = dt / (c.smoothing + dt)
+		}
+		for i := range c.acceleration {
+			raw := (velocity[i] - c.prevVelocity[i]) / dt
+			c.acceleration[i] += alpha * (raw - c.acceleration[i])
+		}
+		c.hasAccel = true
+	} else if c.prev && !(connected && valid) {
+		// Gap, pause or restart - acceleration over it is meaningless
+		c.hasAccel = false
+		c.acceleration = [3]float64{}
+	}
+
+	if c.hasAccel {
+		a := c.acceleration
+		k.HasAcceleration = true
+		k.Acceleration = math.Sqrt(a[0]*a[0] + a[1]*a[1] + a[2]*a[2])
+		// Proper acceleration felt by the drone is acceleration minus gravity, which points down along -Y
+		k.GLoad = math.Sqrt(a[0]*a[0]+(a[1]+Gravity)*(a[1]+Gravity)+a[2]*a[2]) / Gravity
+	}
+
+	c.prev = true
+	c.prevTs = d.Timestamp
+	c.prevPosition = d.Position
+	c.prevVelocity = velocity
+	c.prevValid = valid
+	return k
+}
@@ -0,0 +1,68 @@
+package lot_config_test
+
+import (
+	"math"
+	"testing"
+
+	lot_config "github.com/dladlk/liftoff-telemetry/data"
+)
+
+func TestKinematicsCalculator_Update(t *testing.T) {
+	withVelocity := []lot_config.StreamDataType{lot_config.Timestamp, lot_config.Position, lot_config.Velocity}
+	positionOnly := []lot_config.StreamDataType{lot_config.Timestamp, lot_config.Position}
+	const dt = 0.01
+
+	tests := []struct {
+		name      string
+		fields    []lot_config.StreamDataType
+		datagram  func(ts float64) lot_config.Datagram
+		wantSpeed float64
+		wantVert  float64
+		wantG     float64
+	}{
+		{name: "Hover", fields: withVelocity, datagram: func(ts float64) lot_config.Datagram {
+			return lot_config.Datagram{Timestamp: float32(ts), Position: [3]float32{0, 5, 0}}
+		}, wantG: 1},
+		{name: "Constant velocity", fields: withVelocity, datagram: func(ts float64) lot_config.Datagram {
+			return lot_config.Datagram{Timestamp: float32(ts), Velocity: [3]float32{3, 0, 4}}
+		}, wantSpeed: 5, wantG: 1},
+		{name: "Free fall", fields: withVelocity, datagram: func(ts float64) lot_config.Datagram {
+			return lot_config.Datagram{Timestamp: float32(ts), Velocity: [3]float32{0, float32(-lot_config.Gravity * ts), 0}}
+		}, wantSpeed: lot_config.Gravity * 1, wantVert: -lot_config.Gravity * 1, wantG: 0},
+		{name: "Climb from position", fields: positionOnly, datagram: func(ts float64) lot_config.Datagram {
+			return lot_config.Datagram{Timestamp: float32(ts), Position: [3]float32{0, float32(2 * ts), 0}}
+		}, wantSpeed: 2, wantVert: 2, wantG: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := lot_config.NewKinematicsCalculator(tt.fields, lot_config.DefaultKinematicsSmoothing)
+			var got lot_config.Kinematics
+			for i := 0; i <= 100; i++ {
+				d := tt.datagram(float64(i) * dt)
+				got = c.Update(&d)
+			}
+			if !got.HasAcceleration {
+				t.Fatalf("Update() = %+v, has no acceleration", got)
+			}
+			if math.Abs(got.Speed-tt.wantSpeed) > 0.01 || math.Abs(got.VerticalSpeed-tt.wantVert) > 0.01 || math.Abs(got.GLoad-tt.wantG) > 0.01 {
+				t.Errorf("Update() = %+v, want speed %.2f, vertical %.2f, g-load %.2f", got, tt.wantSpeed, tt.wantVert, tt.wantG)
+			}
+		})
+	}
+}
+
+func TestKinematicsCalculator_Gap(t *testing.T) {
+	c := lot_config.NewKinematicsCalculator([]lot_config.StreamDataType{lot_config.Timestamp, lot_config.Velocity}, 0)
+	c.Update(&lot_config.Datagram{Timestamp: 1})
+	if got := c.Update(&lot_config.Datagram{Timestamp: 1.01, Velocity: [3]float32{3, 0, 4}}); !got.HasAcceleration ||
+		math.Abs(got.Interval-0.01) > 1e-6 || math.Abs(got.VelocityChange-5) > 1e-6 {
+		t.Errorf("Update() = %+v, want acceleration, interval 0.01 and velocity change 5 after 2 datagrams", got)
+	}
+	// Restart with lower timestamp and huge velocity change must not produce acceleration spike
+	if got := c.Update(&lot_config.Datagram{Timestamp: 0, Velocity: [3]float32{100, 0, 0}}); got.HasAcceleration || got.Interval != 0 || got.VelocityChange != 0 {
+		t.Errorf("Update() = %+v, acceleration derived over restart", got)
+	}
+	if got := c.Update(&lot_config.Datagram{Timestamp: 10, Velocity: [3]float32{0, 0, 0}}); got.HasAcceleration || got.Interval != 0 || got.VelocityChange != 0 {
+		t.Errorf("Update() = %+v, acceleration derived over gap", got)
+	}
+}
@@ -0,0 +1,282 @@
+package lot_config
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+)
+
+// Lap is a completed lap between two crossings of the start/finish gate
+type Lap struct {
+	Index   int
+	Start   float64 // Interpolated timestamps of gate crossings, seconds
+	End     float64
+	Time    float64
+	Invalid string    // Reason why the lap does not count, empty for valid laps
+	Sectors []float64 // Times between checkpoints, 0 for missed ones. Empty if the track has no checkpoints.
+	Deltas  []float64 // Differences of sector times to the best ones before this lap, 0 if unknown
+}
+
+func (l Lap) Valid() bool {
+	return l.Invalid == ""
+}
+
+func (l Lap) String() string {
+	res := fmt.Sprintf("lap #%d %.3f s", l.Index, l.Time)
+	if len(l.Sectors) > 0 {
+		res += ", sectors " + FormatSectors(l.Sectors, l.Deltas)
+	}
+	if !l.Valid() {
+		res += " invalid: " + l.Invalid
+	}
+	return res
+}
+
+// Split is a crossing of a checkpoint or of the finish, which ends a sector of the lap
+type Split struct {
+	Lap    int
+	Sector int // Starting with 1
+	Time   float64
+	Delta  float64 // Difference to the best time of the sector, 0 if there is no best yet
+}
+
+func (s Split) String() string {
+	return fmt.Sprintf("lap #%d sector %d: %s", s.Lap, s.Sector, formatSector(s.Time, s.Delta))
+}
+
+// LapEvent is the result of LapTimer update, both fields are empty if nothing is crossed.
+// A fast drone may cross several checkpoints and the finish between two datagrams, so there can be several splits with a lap.
+type LapEvent struct {
+	Splits []Split
+	Lap    *Lap
+}
+
+// FormatSectors formats sector times with deltas to the best ones
+func FormatSectors(sectors []float64, deltas []float64) string {
+	res := make([]string, len(sectors))
+	for i, sector := range sectors {
+		if sector == 0 {
+			res[i] = "missed"
+			continue
+		}
+		var delta float64
+		if i < len(deltas) {
+			delta = deltas[i]
+		}
+		res[i] = formatSector(sector, delta)
+	}
+	return strings.Join(res, ", ")
+}
+
+func formatSector(time, delta float64) string {
+	if delta == 0 {
+		return fmt.Sprintf("%.3f s", time)
+	}
+	return fmt.Sprintf("%.3f s (%+.3f)", time, delta)
+}
+
+// LapTimer times laps by crossings of the start/finish gate of the track and splits them into sectors by its checkpoints.
+// The first crossing starts lap 1, each next crossing in the valid direction completes a lap and starts the next one.
+// Best sectors are kept while the timer is used for the same track.
+type LapTimer struct {
+	track   *Track
+	prev    Datagram
+	hasPrev bool
+	started bool
+	start   float64
+	index   int
+	invalid string
+
+	next        int // Index of the next checkpoint to cross, len(Checkpoints) when only finish is left
+	splitAt     float64
+	sectors     []float64
+	deltas      []float64
+	bestSectors []float64
+	splits      []Split
+	lap         Lap
+}
+
+func NewLapTimer(track *Track) *LapTimer {
+	return &LapTimer{track: track, bestSectors: make([]float64, track.Sectors())}
+}
+
+func (l *LapTimer) Track() *Track {
+	return l.track
+}
+
+// Reset forgets current lap, e.g. when race is restarted. Best sectors are kept.
+func (l *LapTimer) Reset() {
+	*l = LapTimer{track: l.track, bestSectors: l.bestSectors}
+}
+
+// Started tells if the first lap is started
+func (l *LapTimer) Started() bool {
+	return l.started
+}
+
+// LapTime returns time of the current lap at the datagram, false if the first lap is not started
+func (l *LapTimer) LapTime(d *Datagram) (float64, bool) {
+	if !l.started {
+		return 0, false
+	}
+	return float64(d.Timestamp) - l.start, true
+}
+
+// BestSectors returns the best time of each sector of valid laps, 0 if not flown yet
+func (l *LapTimer) BestSectors() []float64 {
+	return l.bestSectors
+}
+
+// TheoreticalBest returns the sum of best sectors, false if some sector has no best time yet or there are no checkpoints
+func (l *LapTimer) TheoreticalBest() (float64, bool) {
+	if len(l.track.Checkpoints) == 0 {
+		return 0, false
+	}
+	var sum float64
+	for _, best := range l.bestSectors {
+		if best == 0 {
+			return 0, false
+		}
+		sum += best
+	}
+	return sum, true
+}
+
+// Update returns splits and a completed lap if the drone crossed checkpoints or start/finish gate since the previous datagram.
+// Returned values are valid until the next call.
+func (l *LapTimer) Update(d *Datagram) LapEvent {
+	if !l.hasPrev {
+		l.hasPrev = true
+		d.CopyTo(&l.prev)
+		return LapEvent{}
+	}
+	defer d.CopyTo(&l.prev)
+	l.splits = l.splits[:0]
+
+	crossing, crossed := l.track.Gate.Cross(&l.prev, d)
+	finish := math.Inf(1)
+	if crossed && crossing.Forward {
+		finish = crossing.Timestamp
+	}
+	// Checkpoints crossed before the finish belong to the current lap, the ones after it to the next lap
+	if l.started {
+		l.crossCheckpoints(d, math.Inf(-1), finish)
+	}
+	event := LapEvent{}
+	if crossed {
+		event.Lap = l.crossGate(crossing)
+	}
+	if l.started && crossed && crossing.Forward {
+		l.crossCheckpoints(d, finish, math.Inf(1))
+	}
+	if len(l.splits) > 0 {
+		event.Splits = l.splits
+	}
+	return event
+}
+
+// crossGate starts the first lap or completes the current one by the crossing of start/finish gate
+func (l *LapTimer) crossGate(crossing GateCrossing) *Lap {
+	if !crossing.Forward {
+		if l.started {
+			l.invalid = "wrong direction"
+		}
+		return nil
+	}
+	if !l.started {
+		l.started = true
+		l.index = 1
+		l.startLap(crossing.Timestamp)
+		return nil
+	}
+
+	if len(l.track.Checkpoints) > 0 {
+		if l.next < len(l.track.Checkpoints) {
+			l.miss(len(l.track.Checkpoints))
+		}
+		l.splitSector(crossing.Timestamp)
+	}
+	l.lap = Lap{Index: l.index, Start: l.start, End: crossing.Timestamp, Time: crossing.Timestamp - l.start, Invalid: l.invalid,
+		Sectors: l.sectors, Deltas: l.deltas}
+	if l.lap.Invalid == "" && l.lap.Time < l.track.MinLapTime {
+		l.lap.Invalid = "short-cut"
+	}
+	if l.lap.Valid() {
+		for i, sector := range l.sectors {
+			if l.bestSectors[i] == 0 || sector < l.bestSectors[i] {
+				l.bestSectors[i] = sector
+			}
+		}
+	}
+	l.index++
+	l.startLap(crossing.Timestamp)
+	return &l.lap
+}
+
+// checkpointCrossing is a forward crossing of the checkpoint with the given index
+type checkpointCrossing struct {
+	index     int
+	timestamp float64
+}
+
+// crossCheckpoints splits sectors by the next or later checkpoints crossed between the timestamps in order of crossing,
+// skipped ones are missed
+func (l *LapTimer) crossCheckpoints(d *Datagram, after, before float64) {
+	var crossings []checkpointCrossing
+	for i := l.next; i < len(l.track.Checkpoints); i++ {
+		crossing, crossed := l.track.Checkpoints[i].Cross(&l.prev, d)
+		if crossed && crossing.Forward && crossing.Timestamp > after && crossing.Timestamp < before {
+			crossings = append(crossings, checkpointCrossing{index: i, timestamp: crossing.Timestamp})
+		}
+	}
+	slices.SortFunc(crossings, func(a, b checkpointCrossing) int {
+		return cmp.Compare(a.timestamp, b.timestamp)
+	})
+	for _, crossing := range crossings {
+		// A checkpoint behind the one crossed earlier is already missed
+		if crossing.index < l.next {
+			continue
+		}
+		l.miss(crossing.index)
+		l.splitSector(crossing.timestamp)
+	}
+}
+
+// miss marks checkpoints before the given one as missed, sectors ending at them get no time
+func (l *LapTimer) miss(checkpoint int) {
+	for ; l.next < checkpoint; l.next++ {
+		l.invalid = "missed checkpoint " + l.track.CheckpointName(l.next)
+		l.sectors = append(l.sectors, 0)
+		l.deltas = append(l.deltas, 0)
+	}
+}
+
+func (l *LapTimer) splitSector(ts float64) {
+	sector := len(l.sectors)
+	time := ts - l.splitAt
+	// Time of a sector after missed checkpoint includes the missed one, so it is not compared
+	if sector > 0 && l.sectors[sector-1] == 0 {
+		time = 0
+	}
+	var delta float64
+	if time > 0 && l.bestSectors[sector] > 0 {
+		delta = time - l.bestSectors[sector]
+	}
+	l.sectors = append(l.sectors, time)
+	l.deltas = append(l.deltas, delta)
+	l.splitAt = ts
+	l.next++
+	l.splits = append(l.splits, Split{Lap: l.index, Sector: sector + 1, Time: time, Delta: delta})
+}
+
+func (l *LapTimer) startLap(ts float64) {
+	l.start = ts
+	l.splitAt = ts
+	l.next = 0
+	l.invalid = ""
+	// Sectors are kept by the returned lap, so new slices are used
+	l.sectors = nil
+	l.deltas = nil
+}
@@ -0,0 +1,225 @@
+package lot_config_test
+
+import (
+	"math"
+	"testing"
+
+	lot_config "github.com/dladlk/liftoff-telemetry/data"
+)
+
+// flight returns datagrams with timestamps every 0.1 s through the given positions
+func flight(positions ...[3]float32) []lot_config.Datagram {
+	res := make([]lot_config.Datagram, len(positions))
+	for i, p := range positions {
+		res[i] = lot_config.Datagram{Timestamp: float32(i) * 0.1, Position: p}
+	}
+	return res
+}
+
+func TestGate_Cross(t *testing.T) {
+	gate := lot_config.Gate{A: [3]float32{-5, 0, 0}, B: [3]float32{5, 0, 0}, Height: 4, Direction: [3]float32{0, 0, 1}}
+	tests := []struct {
+		name        string
+		from, to    [3]float32
+		wantCrossed bool
+		wantForward bool
+		wantTs      float64
+	}{
+		{name: "Through the middle", from: [3]float32{0, 2, -1}, to: [3]float32{0, 2, 3}, wantCrossed: true, wantForward: true, wantTs: 0.025},
+		{name: "Backwards", from: [3]float32{1, 2, 1}, to: [3]float32{1, 2, -1}, wantCrossed: true, wantForward: false, wantTs: 0.05},
+		{name: "Outside of posts", from: [3]float32{6, 2, -1}, to: [3]float32{6, 2, 1}},
+		{name: "Above", from: [3]float32{0, 5, -1}, to: [3]float32{0, 5, 1}},
+		{name: "Not reached", from: [3]float32{0, 2, -3}, to: [3]float32{0, 2, -1}},
+		{name: "Diagonal at speed", from: [3]float32{-20, 1, -20}, to: [3]float32{20, 3, 20}, wantCrossed: true, wantForward: true, wantTs: 0.05},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			from := lot_config.Datagram{Timestamp: 0, Position: tt.from}
+			to := lot_config.Datagram{Timestamp: 0.1, Position: tt.to}
+			got, crossed := gate.Cross(&from, &to)
+			if crossed != tt.wantCrossed {
+				t.Fatalf("Cross() crossed = %v, want %v", crossed, tt.wantCrossed)
+			}
+			if crossed && (got.Forward != tt.wantForward || math.Abs(got.Timestamp-tt.wantTs) > 1e-6) {
+				t.Errorf("Cross() = %+v, want forward %v at %v", got, tt.wantForward, tt.wantTs)
+			}
+		})
+	}
+}
+
+func TestLapTimer(t *testing.T) {
+	track := lot_config.Track{
+		Name:       "Test",
+		Gate:       lot_config.Gate{A: [3]float32{-5, 0, 0}, B: [3]float32{5, 0, 0}, Direction: [3]float32{0, 0, 1}},
+		MinLapTime: 0.35,
+	}
+	before, after, away := [3]float32{0, 1, -1}, [3]float32{0, 1, 1}, [3]float32{0, 1, 50}
+	around := [3]float32{20, 1, 0} // Beside the gate, going back to the start
+
+	tests := []struct {
+		name      string
+		positions [][3]float32
+		want      []lot_config.Lap
+	}{
+		{
+			name:      "Two laps",
+			positions: [][3]float32{before, after, away, around, before, after, away, around, before, after},
+			want: []lot_config.Lap{
+				{Index: 1, Start: 0.05, End: 0.45, Time: 0.4},
+				{Index: 2, Start: 0.45, End: 0.85, Time: 0.4},
+			},
+		},
+		{
+			name:      "Wrong direction",
+			positions: [][3]float32{before, after, away, after, before, around, before, after},
+			want:      []lot_config.Lap{{Index: 1, Start: 0.05, End: 0.65, Time: 0.6, Invalid: "wrong direction"}},
+		},
+		{
+			name:      "Short-cut",
+			positions: [][3]float32{before, after, around, before, after},
+			want:      []lot_config.Lap{{Index: 1, Start: 0.05, End: 0.35, Time: 0.3, Invalid: "short-cut"}},
+		},
+		{
+			name:      "Not started",
+			positions: [][3]float32{away, around, before},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			timer := lot_config.NewLapTimer(&track)
+			var got []lot_config.Lap
+			datagrams := flight(tt.positions...)
+			for i := range datagrams {
+				if event := timer.Update(&datagrams[i]); event.Lap != nil {
+					got = append(got, *event.Lap)
+				}
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("Laps = %v, want %v", got, tt.want)
+			}
+			for i, lap := range got {
+				want := tt.want[i]
+				if lap.Index != want.Index || lap.Invalid != want.Invalid ||
+					math.Abs(lap.Start-want.Start) > 1e-6 || math.Abs(lap.End-want.End) > 1e-6 || math.Abs(lap.Time-want.Time) > 1e-6 {
+					t.Errorf("Lap %d = %+v, want %+v", i, lap, want)
+				}
+			}
+		})
+	}
+}
+
+func TestLapTimer_Checkpoints(t *testing.T) {
+	track := lot_config.Track{
+		Name:        "Test",
+		Gate:        lot_config.Gate{A: [3]float32{-5, 0, 0}, B: [3]float32{5, 0, 0}, Direction: [3]float32{0, 0, 1}},
+		Checkpoints: []lot_config.Gate{{Name: "Far", A: [3]float32{-5, 0, 50}, B: [3]float32{5, 0, 50}, Direction: [3]float32{0, 0, 1}}},
+	}
+	before, after := [3]float32{0, 1, -1}, [3]float32{0, 1, 1}
+	cpBefore, cpAfter := [3]float32{0, 1, 49}, [3]float32{0, 1, 51}
+	// Back to the start beside the gates
+	around, back, detour := [3]float32{20, 1, 25}, [3]float32{20, 1, -10}, [3]float32{25, 1, -10}
+
+	timer := lot_config.NewLapTimer(&track)
+	datagrams := flight(
+		before, after, cpBefore, cpAfter, around, back, before, after, // Lap 1
+		cpBefore, cpAfter, around, back, detour, before, after, // Lap 2 with slower second sector
+		around, back, before, after, // Lap 3 without checkpoint
+	)
+	var splits []lot_config.Split
+	var laps []lot_config.Lap
+	for i := range datagrams {
+		event := timer.Update(&datagrams[i])
+		splits = append(splits, event.Splits...)
+		if event.Lap != nil {
+			laps = append(laps, *event.Lap)
+		}
+	}
+
+	wantSplits := []lot_config.Split{
+		{Lap: 1, Sector: 1, Time: 0.2}, {Lap: 1, Sector: 2, Time: 0.4},
+		{Lap: 2, Sector: 1, Time: 0.2}, {Lap: 2, Sector: 2, Time: 0.5, Delta: 0.1},
+		{Lap: 3, Sector: 2},
+	}
+	if len(splits) != len(wantSplits) {
+		t.Fatalf("Splits = %v, want %v", splits, wantSplits)
+	}
+	for i, split := range splits {
+		want := wantSplits[i]
+		if split.Lap != want.Lap || split.Sector != want.Sector || math.Abs(split.Time-want.Time) > 1e-5 || math.Abs(split.Delta-want.Delta) > 1e-5 {
+			t.Errorf("Split %d = %v, want %v", i, split, want)
+		}
+	}
+
+	if len(laps) != 3 {
+		t.Fatalf("Laps = %v, want 3", laps)
+	}
+	if !laps[0].Valid() || !laps[1].Valid() || laps[2].Invalid != "missed checkpoint Far" {
+		t.Errorf("Laps = %v, want last one invalid by missed checkpoint", laps)
+	}
+	if laps[2].Sectors[0] != 0 {
+		t.Errorf("Missed sector time = %v, want 0", laps[2].Sectors[0])
+	}
+	if best, ok := timer.TheoreticalBest(); !ok || math.Abs(best-0.6) > 1e-5 {
+		t.Errorf("TheoreticalBest() = %v, %v, want 0.6", best, ok)
+	}
+
+	timer.Reset()
+	if best, ok := timer.TheoreticalBest(); !ok || math.Abs(best-0.6) > 1e-5 {
+		t.Errorf("TheoreticalBest() after Reset() = %v, %v, want best sectors kept", best, ok)
+	}
+}
+
+func TestLapTimer_CrossingsInOneSegment(t *testing.T) {
+	track := lot_config.Track{
+		Name: "Test",
+		Gate: lot_config.Gate{A: [3]float32{-5, 0, 0}, B: [3]float32{5, 0, 0}, Direction: [3]float32{0, 0, 1}},
+		Checkpoints: []lot_config.Gate{
+			{Name: "Near", A: [3]float32{-5, 0, -2}, B: [3]float32{5, 0, -2}, Direction: [3]float32{0, 0, 1}},
+			{Name: "Nearer", A: [3]float32{-5, 0, -1.5}, B: [3]float32{5, 0, -1.5}, Direction: [3]float32{0, 0, 1}},
+		},
+	}
+	// The last move at speed crosses both checkpoints and the finish
+	timer := lot_config.NewLapTimer(&track)
+	datagrams := flight([3]float32{0, 1, -1}, [3]float32{0, 1, 1}, [3]float32{0, 1, 30}, [3]float32{20, 1, 10}, [3]float32{20, 1, -10},
+		[3]float32{0, 1, -3}, [3]float32{0, 1, 1})
+	var event lot_config.LapEvent
+	for i := range datagrams {
+		event = timer.Update(&datagrams[i])
+	}
+
+	wantSplits := []lot_config.Split{{Lap: 1, Sector: 1, Time: 0.475}, {Lap: 1, Sector: 2, Time: 0.0125}, {Lap: 1, Sector: 3, Time: 0.0375}}
+	if len(event.Splits) != len(wantSplits) {
+		t.Fatalf("Splits = %v, want %v", event.Splits, wantSplits)
+	}
+	for i, split := range event.Splits {
+		want := wantSplits[i]
+		if split.Lap != want.Lap || split.Sector != want.Sector || math.Abs(split.Time-want.Time) > 1e-5 {
+			t.Errorf("Split %d = %v, want %v", i, split, want)
+		}
+	}
+	if event.Lap == nil {
+		t.Fatalf("Lap = nil, want lap completed with the checkpoints")
+	}
+	if !event.Lap.Valid() || math.Abs(event.Lap.Time-0.525) > 1e-5 {
+		t.Errorf("Lap = %v, want valid lap of 0.525 s", event.Lap)
+	}
+}
+
+func TestFindTrack(t *testing.T) {
+	tracks := []lot_config.Track{
+		{Name: "Far", Spawn: []float32{100, 0, 100}},
+		{Name: "Near", Spawn: []float32{10, 0, 0}},
+		{Name: "No spawn"},
+	}
+	start := lot_config.Datagram{Position: [3]float32{12, 0, 0}}
+	if got := lot_config.FindTrack(tracks, "", &start); got == nil || got.Name != "Near" {
+		t.Errorf("FindTrack() by spawn = %v, want Near", got)
+	}
+	if got := lot_config.FindTrack(tracks, "No spawn", &start); got == nil || got.Name != "No spawn" {
+		t.Errorf("FindTrack() by name = %v, want No spawn", got)
+	}
+	start.Position = [3]float32{50, 0, 50}
+	if got := lot_config.FindTrack(tracks, "", &start); got != nil {
+		t.Errorf("FindTrack() far from spawns = %v, want nil", got)
+	}
+}
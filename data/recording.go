@@ -0,0 +1,52 @@
+package lot_config
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// RecordingReader reads blocks of a binary recording: header line with stream formats followed by raw Liftoff packets
+type RecordingReader struct {
+	reader            *bufio.Reader
+	streamFormatNames []string
+	fields            []StreamDataType
+	buf               []byte
+}
+
+func NewRecordingReader(reader io.Reader) (*RecordingReader, error) {
+	r := &RecordingReader{reader: bufio.NewReader(reader)}
+	header, err := r.reader.ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("Failed to read header: %w", err)
+	}
+	header = strings.TrimSpace(header)
+	if header == "" {
+		return nil, errors.New("Recording has no header with stream formats")
+	}
+	r.streamFormatNames = strings.Split(header, ",")
+	r.fields = ParseStreamDataTypeFormats(r.streamFormatNames)
+	for i, field := range r.fields {
+		if field == Unknown {
+			return nil, fmt.Errorf("Unknown stream format '%s' in recording header", r.streamFormatNames[i])
+		}
+	}
+	return r, nil
+}
+
+func (r *RecordingReader) StreamFormatNames() []string {
+	return r.streamFormatNames
+}
+
+func (r *RecordingReader) Fields() []StreamDataType {
+	return r.fields
+}
+
+// Next returns the next raw block, valid until the following call. Returns io.EOF after the last one.
+func (r *RecordingReader) Next() ([]byte, error) {
+	var err error
+	r.buf, err = ReadBlock(r.reader, r.fields, r.buf)
+	return r.buf, err
+}
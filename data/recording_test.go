@@ -0,0 +1,55 @@
+package lot_config_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	lot_config "github.com/dladlk/liftoff-telemetry/data"
+)
+
+func TestRecordingReader(t *testing.T) {
+	names := []string{"Timestamp", "Position", "MotorRPM"}
+	fields := lot_config.ParseStreamDataTypeFormats(names)
+	var recording bytes.Buffer
+	recording.WriteString("Timestamp,Position,MotorRPM\n")
+	var want [][]byte
+	for i := 0; i < 3; i++ {
+		d := lot_config.Datagram{Timestamp: float32(i), Position: [3]float32{1, 2, 3}, MotorRPM: []float32{1, 2, 3, 4}}
+		block := d.Encode(fields)
+		want = append(want, block)
+		recording.Write(block)
+	}
+	recording.Write(want[0][:5])
+
+	r, err := lot_config.NewRecordingReader(&recording)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Fields()) != len(names) {
+		t.Errorf("Fields() = %v, want %v", r.Fields(), names)
+	}
+	for i := range want {
+		block, err := r.Next()
+		if err != nil {
+			t.Fatalf("Next() block %d failed: %v", i, err)
+		}
+		if !bytes.Equal(block, want[i]) {
+			t.Errorf("Next() block %d = %v, want %v", i, block, want[i])
+		}
+	}
+	if _, err := r.Next(); !errors.Is(err, lot_config.ErrTruncated) {
+		t.Errorf("Next() at truncated tail = %v, want ErrTruncated", err)
+	}
+
+	r, _ = lot_config.NewRecordingReader(bytes.NewBufferString("Timestamp\n"))
+	if _, err := r.Next(); err != io.EOF {
+		t.Errorf("Next() of empty recording = %v, want io.EOF", err)
+	}
+	for _, header := range []string{"", "\n", "Timestamp,Speed\n"} {
+		if _, err := lot_config.NewRecordingReader(bytes.NewBufferString(header)); err == nil {
+			t.Errorf("NewRecordingReader(%q) must fail", header)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package lot_config
+
+import "fmt"
+
+// SessionState is the state of race detection
+type SessionState int
+
+const (
+	// SessionWaiting is before the first datagram with position, e.g. in menu
+	SessionWaiting SessionState = iota
+	// SessionRacing is a session in progress
+	SessionRacing
+	// SessionFinished is after the race is finished, Liftoff sends zero position until a new one starts
+	SessionFinished
+	// SessionRestarted is a session started by restart of the race until the drone moves from the start
+	SessionRestarted
+)
+
+var sessionStateNames = [...]string{"waiting", "racing", "finished", "restarted"}
+
+func (s SessionState) String() string {
+	if int(s) < len(sessionStateNames) {
+		return sessionStateNames[s]
+	}
+	return "unknown"
+}
+
+type SessionEventKind int
+
+const (
+	SessionStarted SessionEventKind = iota
+	// SessionEnded is a finished or restarted session in which the drone moved
+	SessionEnded
+	// SessionDiscarded is a session ended before the drone moved from the start, its index is reused
+	SessionDiscarded
+)
+
+var sessionEventNames = [...]string{"started", "ended", "discarded"}
+
+func (k SessionEventKind) String() string {
+	if int(k) < len(sessionEventNames) {
+		return sessionEventNames[k]
+	}
+	return "unknown"
+}
+
+// SessionEndReason tells why a session ended
+type SessionEndReason int
+
+const (
+	EndNone SessionEndReason = iota
+	// EndFinished is sending of zero position after the race
+	EndFinished
+	// EndRestarted is a jump of timestamp back to the start of the race
+	EndRestarted
+	// EndStopped is the end of the stream, e.g. recorder is stopped
+	EndStopped
+)
+
+var sessionEndReasonNames = [...]string{"", "finished", "restarted", "stopped"}
+
+func (r SessionEndReason) String() string {
+	if int(r) < len(sessionEndReasonNames) {
+		return sessionEndReasonNames[r]
+	}
+	return "unknown"
+}
+
+type SessionEvent struct {
+	Kind   SessionEventKind
+	Reason SessionEndReason // Only for ended and discarded sessions
+	Index  int              // Number of the session, starting with 1
+	Events int              // Datagrams in the session, only for ended and discarded sessions
+}
+
+func (e SessionEvent) String() string {
+	if e.Kind == SessionStarted {
+		return fmt.Sprintf("session #%d started", e.Index)
+	}
+	return fmt.Sprintf("session #%d %v (%v) after %d events", e.Index, e.Kind, e.Reason, e.Events)
+}
+
+// SessionMinDistance is the distance from the start the drone must move for the session not to be discarded
+const SessionMinDistance = 1.0
+
+// SessionSegmenter splits a stream of datagrams into race sessions.
+// A session starts with the first datagram with position, ends when the race is restarted
+// or when zero position is sent twice in a row after finish.
+// Restarts are told by health analysis of timestamps, late packets are ignored.
+type SessionSegmenter struct {
+	hasPosition  bool
+	hasTimestamp bool
+	state        SessionState
+	index        int
+	events       int
+	moved        bool
+	start        Datagram
+	prevZero     bool
+	buf          []SessionEvent
+	health       *HealthAnalyzer
+	healthEvent  HealthEvent
+}
+
+func NewSessionSegmenter(fields []StreamDataType) *SessionSegmenter {
+	s := &SessionSegmenter{health: NewHealthAnalyzer()}
+	s.SetFields(fields)
+	return s
+}
+
+// SetFields changes fields the datagrams are sent with, e.g. when telemetry config is reloaded, the current session goes on
+func (s *SessionSegmenter) SetFields(fields []StreamDataType) {
+	s.hasPosition, s.hasTimestamp = false, false
+	for _, field := range fields {
+		switch field {
+		case Position:
+			s.hasPosition = true
+		case Timestamp:
+			s.hasTimestamp = true
+		}
+	}
+}
+
+func (s *SessionSegmenter) State() SessionState {
+	return s.state
+}
+
+// InSession tells if the last datagram belongs to a session, datagrams outside of sessions should be ignored
+func (s *SessionSegmenter) InSession() bool {
+	return s.state == SessionRacing || s.state == SessionRestarted
+}
+
+// Index returns number of the current or the last session
+func (s *SessionSegmenter) Index() int {
+	return s.index
+}
+
+// Health returns analyzer of the stream timestamps
+func (s *SessionSegmenter) Health() *HealthAnalyzer {
+	return s.health
+}
+
+// HealthEvent returns health of the last datagram, reordered one is ignored and should be ignored by the caller too
+func (s *SessionSegmenter) HealthEvent() HealthEvent {
+	return s.healthEvent
+}
+
+// Update returns events caused by the datagram, valid until the next call. Restart gives 2 events: end of the previous session and start of the new one.
+func (s *SessionSegmenter) Update(d *Datagram) []SessionEvent {
+	s.buf = s.buf[:0]
+	s.healthEvent = HealthEvent{Kind: HealthOk}
+	if s.hasTimestamp {
+		s.healthEvent = s.health.Update(d.Timestamp)
+	}
+	if s.healthEvent.Kind == HealthReordered {
+		return s.buf
+	}
+	zero := s.hasPosition && d.ZeroPosition()
+	restarted := s.state != SessionWaiting && s.healthEvent.Kind == HealthRestarted
+
+	switch s.state {
+	case SessionWaiting, SessionFinished:
+		if !zero {
+			s.begin(d, SessionRacing)
+		}
+	case SessionRacing, SessionRestarted:
+		switch {
+		case restarted:
+			s.end(EndRestarted)
+			s.begin(d, SessionRestarted)
+		case zero && s.prevZero:
+			s.end(EndFinished)
+			s.state = SessionFinished
+		default:
+			s.events++
+			if !s.moved && !zero && d.DistanceFrom(&s.start) > SessionMinDistance {
+				s.moved = true
+				s.state = SessionRacing
+			}
+		}
+	}
+	s.prevZero = zero
+	return s.buf
+}
+
+// Stop ends the current session at the end of the stream
+func (s *SessionSegmenter) Stop() []SessionEvent {
+	s.buf = s.buf[:0]
+	if s.InSession() {
+		s.end(EndStopped)
+	}
+	s.state = SessionWaiting
+	s.prevZero = false
+	return s.buf
+}
+
+func (s *SessionSegmenter) begin(d *Datagram, state SessionState) {
+	s.index++
+	s.events = 1
+	s.moved = !s.hasPosition
+	d.CopyTo(&s.start)
+	s.state = state
+	if s.moved {
+		s.state = SessionRacing
+	}
+	s.buf = append(s.buf, SessionEvent{Kind: SessionStarted, Index: s.index})
+}
+
+func (s *SessionSegmenter) end(reason SessionEndReason) {
+	kind := SessionEnded
+	if !s.moved {
+		kind = SessionDiscarded
+	}
+	s.buf = append(s.buf, SessionEvent{Kind: kind, Reason: reason, Index: s.index, Events: s.events})
+	if kind == SessionDiscarded {
+		s.index--
+	}
+}
@@ -0,0 +1,124 @@
+package lot_config_test
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+
+	lot_config "github.com/dladlk/liftoff-telemetry/data"
+)
+
+// recordedRace holds timestamp and position of each 100th packet of a recorded race: standing on the start,
+// flying away and the race restarted back to the start
+var recordedRace = []lot_config.Datagram{
+	{Timestamp: 0, Position: [3]float32{15.752, 0.034, -66.501}},
+	{Timestamp: 1, Position: [3]float32{15.752, 0, -66.501}},
+	{Timestamp: 18, Position: [3]float32{15.752, 0, -66.501}},
+	{Timestamp: 19, Position: [3]float32{15.764, 0.361, -66.456}},
+	{Timestamp: 20, Position: [3]float32{15.845, 0.31, -65.53}},
+	{Timestamp: 21, Position: [3]float32{16.027, 0.271, -62.96}},
+	{Timestamp: 22, Position: [3]float32{15.99, 0.375, -58.761}},
+	{Timestamp: 23, Position: [3]float32{15.679, 0.619, -53.257}},
+	{Timestamp: 24, Position: [3]float32{15.923, 0.406, -46.689}},
+	{Timestamp: 25, Position: [3]float32{16.499, 0.385, -39.212}},
+	{Timestamp: 26, Position: [3]float32{16.462, 0.511, -31.144}},
+	{Timestamp: 27, Position: [3]float32{14.526, 0.558, -22.937}},
+	{Timestamp: 28, Position: [3]float32{11.716, 0.674, -14.762}},
+	{Timestamp: 29, Position: [3]float32{9.473, 0.757, -6.486}},
+	{Timestamp: 30, Position: [3]float32{7.421, 0.165, 1.319}},
+	{Timestamp: 31, Position: [3]float32{3.301, 0.357, 6.571}},
+	{Timestamp: 32, Position: [3]float32{-1.514, 0.29, 9.741}},
+	{Timestamp: 33, Position: [3]float32{-4.272, 0, 11.289}},
+	{Timestamp: 34, Position: [3]float32{-4.483, 0.016, 11.266}},
+	{Timestamp: 0, Position: [3]float32{15.752, 0.034, -66.501}},
+}
+
+func TestSessionSegmenter(t *testing.T) {
+	recorded := recordedRace
+	lines := func(from, to int) []lot_config.Datagram {
+		return slices.Clone(recorded[from : to+1])
+	}
+	zero := func(ts float32) lot_config.Datagram {
+		return lot_config.Datagram{Timestamp: ts}
+	}
+	late := recorded[5]
+	late.Timestamp -= 0.05
+	// Restart sends growing timestamps a bit lower than the last one
+	closeRestart := lines(0, 4)
+	for i := range closeRestart {
+		closeRestart[i].Timestamp = recorded[5].Timestamp - 0.09 + float32(i)*0.01
+	}
+
+	started := func(index int) lot_config.SessionEvent {
+		return lot_config.SessionEvent{Kind: lot_config.SessionStarted, Index: index}
+	}
+	ended := func(index, events int, reason lot_config.SessionEndReason) lot_config.SessionEvent {
+		return lot_config.SessionEvent{Kind: lot_config.SessionEnded, Index: index, Events: events, Reason: reason}
+	}
+	discarded := func(index, events int, reason lot_config.SessionEndReason) lot_config.SessionEvent {
+		return lot_config.SessionEvent{Kind: lot_config.SessionDiscarded, Index: index, Events: events, Reason: reason}
+	}
+
+	tests := []struct {
+		name      string
+		datagrams []lot_config.Datagram
+		want      []lot_config.SessionEvent
+		wantState lot_config.SessionState
+	}{
+		{
+			name:      "Race restarted",
+			datagrams: lines(0, 19),
+			want:      []lot_config.SessionEvent{started(1), ended(1, 19, lot_config.EndRestarted), started(2), discarded(2, 1, lot_config.EndStopped)},
+			wantState: lot_config.SessionRestarted,
+		},
+		{
+			name:      "Restart on the start is discarded",
+			datagrams: append(lines(0, 2), recorded[19]),
+			want:      []lot_config.SessionEvent{started(1), discarded(1, 3, lot_config.EndRestarted), started(1), discarded(1, 1, lot_config.EndStopped)},
+			wantState: lot_config.SessionRestarted,
+		},
+		{
+			name:      "Race finished",
+			datagrams: append(append(lines(0, 18), zero(35), zero(35.1), zero(35.2)), lines(0, 5)...),
+			want:      []lot_config.SessionEvent{started(1), ended(1, 20, lot_config.EndFinished), started(2), ended(2, 6, lot_config.EndStopped)},
+			wantState: lot_config.SessionRacing,
+		},
+		{
+			name:      "Zero position before race",
+			datagrams: append([]lot_config.Datagram{zero(0), zero(0)}, lines(0, 1)...),
+			want:      []lot_config.SessionEvent{started(1), discarded(1, 2, lot_config.EndStopped)},
+			wantState: lot_config.SessionRacing,
+		},
+		{
+			name:      "Late packet is not restart",
+			datagrams: append(lines(0, 5), late, recorded[6]),
+			want:      []lot_config.SessionEvent{started(1), ended(1, 7, lot_config.EndStopped)},
+			wantState: lot_config.SessionRacing,
+		},
+		{
+			name:      "Restart close to the last timestamp",
+			datagrams: append(lines(0, 5), closeRestart...),
+			want:      []lot_config.SessionEvent{started(1), ended(1, 6, lot_config.EndRestarted), started(2), discarded(2, 1, lot_config.EndStopped)},
+			wantState: lot_config.SessionRestarted,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := lot_config.NewSessionSegmenter(allFields)
+			var got []lot_config.SessionEvent
+			for i := range tt.datagrams {
+				got = append(got, s.Update(&tt.datagrams[i])...)
+			}
+			if s.State() != tt.wantState {
+				t.Errorf("State() = %v, want %v", s.State(), tt.wantState)
+			}
+			got = append(got, s.Stop()...)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Events = %v, want %v", got, tt.want)
+			}
+			if s.InSession() {
+				t.Errorf("InSession() after Stop() = true")
+			}
+		})
+	}
+}
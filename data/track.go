@@ -0,0 +1,155 @@
+package lot_config
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// TrackSpawnRadius is the distance in meters from the configured spawn point within which a session start selects the track
+const TrackSpawnRadius = 20.0
+
+// DefaultGateHeight is used if gate height is not configured, meters
+const DefaultGateHeight = 10.0
+
+// Gate is a vertical rectangle between two posts standing at A and B, crossed when the drone flies through it.
+// Direction, if set, is the valid direction of crossing, otherwise any direction is valid.
+type Gate struct {
+	Name      string     `toml:"name"`
+	A         [3]float32 `toml:"a"`
+	B         [3]float32 `toml:"b"`
+	Height    float32    `toml:"height"`
+	Direction [3]float32 `toml:"direction"`
+}
+
+// Track defines gates of a Liftoff track, which is selected by name or by spawn position of the drone
+type Track struct {
+	Name        string    `toml:"name"`
+	Spawn       []float32 `toml:"spawn"`
+	Gate        Gate      `toml:"gate"`
+	Checkpoints []Gate    `toml:"checkpoints"` // Must be crossed in order during each lap
+	MinLapTime  float64   `toml:"minLapTime"`  // Shorter laps are short-cuts
+}
+
+// Sectors returns number of sectors the checkpoints split the lap into, 0 if there are no checkpoints
+func (t Track) Sectors() int {
+	if len(t.Checkpoints) == 0 {
+		return 0
+	}
+	return len(t.Checkpoints) + 1
+}
+
+// CheckpointName returns name of the checkpoint or its number starting with 1, if it has no name
+func (t Track) CheckpointName(i int) string {
+	if name := t.Checkpoints[i].Name; name != "" {
+		return name
+	}
+	return fmt.Sprint(i + 1)
+}
+
+// GateCrossing is a pass of the drone through the gate between two datagrams
+type GateCrossing struct {
+	Timestamp float64 // Interpolated between timestamps of the datagrams
+	Forward   bool    // Crossed in the valid direction
+}
+
+func (g Gate) Validate() error {
+	if g.A[0] == g.B[0] && g.A[2] == g.B[2] {
+		return fmt.Errorf("gate %s posts a and b must be at different horizontal positions", g.Name)
+	}
+	if g.Height < 0 {
+		return fmt.Errorf("gate %s height must not be negative", g.Name)
+	}
+	return nil
+}
+
+func (t Track) Validate() error {
+	if t.Name == "" {
+		return errors.New("track name is empty")
+	}
+	if len(t.Spawn) != 0 && len(t.Spawn) != 3 {
+		return fmt.Errorf("track %s spawn must have 3 coordinates", t.Name)
+	}
+	if err := t.Gate.Validate(); err != nil {
+		return fmt.Errorf("track %s: %w", t.Name, err)
+	}
+	for i, checkpoint := range t.Checkpoints {
+		if err := checkpoint.Validate(); err != nil {
+			return fmt.Errorf("track %s checkpoint %s: %w", t.Name, t.CheckpointName(i), err)
+		}
+	}
+	return nil
+}
+
+// Cross checks if the drone flew through the gate between datagrams from and to
+func (g Gate) Cross(from, to *Datagram) (GateCrossing, bool) {
+	a := vec(g.A)
+	along := sub(vec(g.B), a)
+	along[1] = 0
+	// Horizontal normal of the gate plane
+	normal := [3]float64{-along[2], 0, along[0]}
+
+	p0, p1 := vec(from.Position), vec(to.Position)
+	s0, s1 := dot3(sub(p0, a), normal), dot3(sub(p1, a), normal)
+	if (s0 < 0) == (s1 < 0) || s0 == s1 {
+		return GateCrossing{}, false
+	}
+	t := s0 / (s0 - s1)
+	move := sub(p1, p0)
+	q := [3]float64{p0[0] + t*move[0], p0[1] + t*move[1], p0[2] + t*move[2]}
+
+	// Between the posts and from the lower post base up to the height above the higher one
+	u := dot3(sub(q, a), along) / dot3(along, along)
+	height := float64(g.Height)
+	if height == 0 {
+		height = DefaultGateHeight
+	}
+	bottom := math.Min(float64(g.A[1]), float64(g.B[1]))
+	top := math.Max(float64(g.A[1]), float64(g.B[1])) + height
+	if u < 0 || u > 1 || q[1] < bottom || q[1] > top {
+		return GateCrossing{}, false
+	}
+
+	forward := true
+	if g.Direction != [3]float32{} {
+		forward = dot3(move, vec(g.Direction)) > 0
+	}
+	ts := float64(from.Timestamp) + t*float64(to.Timestamp-from.Timestamp)
+	return GateCrossing{Timestamp: ts, Forward: forward}, true
+}
+
+// FindTrack returns track by name, or if name is empty, the one with spawn nearest to the start position within TrackSpawnRadius
+func FindTrack(tracks []Track, name string, start *Datagram) *Track {
+	var found *Track
+	best := TrackSpawnRadius
+	for i := range tracks {
+		track := &tracks[i]
+		if name != "" {
+			if track.Name == name {
+				return track
+			}
+			continue
+		}
+		if len(track.Spawn) != 3 || start == nil {
+			continue
+		}
+		spawn := Datagram{Position: [3]float32{track.Spawn[0], track.Spawn[1], track.Spawn[2]}}
+		if distance := start.DistanceFrom(&spawn); distance <= best {
+			best = distance
+			found = track
+		}
+	}
+	return found
+}
+
+func vec(v [3]float32) [3]float64 {
+	return [3]float64{float64(v[0]), float64(v[1]), float64(v[2])}
+}
+
+func sub(a, b [3]float64) [3]float64 {
+	return [3]float64{a[0] - b[0], a[1] - b[1], a[2] - b[2]}
+}
+
+func dot3(a, b [3]float64) float64 {
+	return a[0]*b[0] + a[1]*b[1] + a[2]*b[2]
+}
@@ -0,0 +1,282 @@
+package lot_config
+
+import (
+	"fmt"
+	"math"
+)
+
+type TrickKind int
+
+const (
+	TrickFlip TrickKind = iota
+	TrickRoll
+	// TrickPowerLoop is a backward pitch loop over the top gaining altitude
+	TrickPowerLoop
+	// TrickMattyFlip is a forward pitch loop over the top gaining altitude
+	TrickMattyFlip
+	// TrickSplitS is a half roll to inverted followed by a half loop down
+	TrickSplitS
+	TrickDive
+)
+
+var trickKindNames = [...]string{"flip", "roll", "power loop", "matty flip", "split-S", "dive"}
+
+// Base style points of each kind, flips and rolls get them per rotation
+var trickPoints = [...]float64{1, 1, 3, 4, 2, 2}
+
+func (k TrickKind) String() string {
+	if int(k) < len(trickKindNames) {
+		return trickKindNames[k]
+	}
+	return "unknown"
+}
+
+func (k TrickKind) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+type Trick struct {
+	Kind      TrickKind `json:"kind"`
+	Start     float32   `json:"start"` // Timestamps, seconds
+	End       float32   `json:"end"`
+	Rotations int       `json:"rotations,omitempty"` // Of flips and rolls
+	Height    float64   `json:"height,omitempty"`    // Altitude gained by loops or lost by split-S and dives, meters
+	Points    float64   `json:"points"`
+}
+
+func (t Trick) String() string {
+	s := t.Kind.String()
+	if t.Rotations > 1 {
+		s += fmt.Sprintf(" x%d", t.Rotations)
+	}
+	s += fmt.Sprintf(" at %.2f..%.2f s", t.Start, t.End)
+	if t.Height != 0 {
+		s += fmt.Sprintf(", %.1f m", t.Height)
+	}
+	return s + fmt.Sprintf(", %.1f points", t.Points)
+}
+
+// StyleScore sums points of the tricks with a bonus of 25% for each kind after the first one
+func StyleScore(tricks []Trick) float64 {
+	var points float64
+	var kinds [len(trickKindNames)]bool
+	distinct := 0
+	for _, trick := range tricks {
+		points += trick.Points
+		if !kinds[trick.Kind] {
+			kinds[trick.Kind] = true
+			distinct++
+		}
+	}
+	if distinct == 0 {
+		return 0
+	}
+	return points * (1 + 0.25*float64(distinct-1))
+}
+
+const (
+	// Pitch or roll rate in degrees/second from which the drone is rotating
+	trickRate = 180.0
+	// Time in seconds the rates must stay below trickRate to end the rotation
+	trickSettle = 0.2
+	// Longer rotation in seconds is tumbling, not a trick
+	trickMaxTime = 4.0
+	// Rotation angle in degrees missing to a full turn which still counts
+	trickTurnTolerance = 60.0
+	// Change of pitch in degrees which tells if the nose goes up or down at the start of a loop
+	trickNosePitch = 45.0
+	// Altitude in meters a loop must gain, and a split-S must lose
+	trickLoopHeight = 3.0
+	// Vertical speed in meters/second, time in seconds and altitude in meters of a dive
+	trickDiveSpeed = 8.0
+	trickDiveTime  = 1.0
+	trickDiveDrop  = 10.0
+)
+
+// TrickDetector recognizes freestyle tricks by pitch and roll rates of Gyro integrated over time, and altitude.
+// Positive pitch rate is nose up and positive roll rate is right wing down, as pilot signs of EulerAngles.
+// If Attitude is sent, it confirms the rotation: the drone must turn upside down and end upright,
+// and the direction of a loop is told by the nose going up or down, so gyro drift and sign do not decide it.
+// Time since the previous datagram and vertical speed are taken from Kinematics of the datagram.
+type TrickDetector struct {
+	hasPosition bool
+	hasGyro     bool
+	hasAttitude bool
+
+	tricks []Trick
+
+	// Rotation in progress
+	rotating    bool
+	start       float32
+	calm        float32 // Timestamp since rates are below trickRate, negative if they are not
+	pitch, roll float64 // Integrated angles, degrees
+	startAlt    float64
+	maxAlt      float64
+	tumbling    bool
+	startPitch  float64 // Of EulerAngles, degrees
+	nose        int     // 1 if the nose went up first, -1 if down, 0 if not known yet
+	inverted    bool    // Drone was upside down
+
+	// Dive in progress
+	diving   bool
+	diveTs   float32
+	diveAlt  float64
+	diveDrop float64
+}
+
+func NewTrickDetector(fields []StreamDataType) *TrickDetector {
+	t := &TrickDetector{calm: -1}
+	for _, field := range fields {
+		switch field {
+		case Position:
+			t.hasPosition = true
+		case Gyro:
+			t.hasGyro = true
+		case Attitude:
+			t.hasAttitude = true
+		}
+	}
+	return t
+}
+
+// Reset forgets unfinished tricks, e.g. when race is restarted
+func (t *TrickDetector) Reset() {
+	*t = TrickDetector{hasPosition: t.hasPosition, hasGyro: t.hasGyro, hasAttitude: t.hasAttitude, tricks: t.tricks[:0], calm: -1}
+}
+
+// Update returns tricks finished by the datagram with its kinematics. Returned slice is reused by the next call.
+func (t *TrickDetector) Update(d *Datagram, k *Kinematics) []Trick {
+	t.tricks = t.tricks[:0]
+	if k.Interval == 0 {
+		// Gap, pause or restart - unfinished tricks are lost
+		t.rotating = false
+		t.diving = false
+		return t.tricks
+	}
+	prevTs := d.Timestamp - float32(k.Interval)
+	if t.hasGyro {
+		t.updateRotation(d, k, prevTs)
+	}
+	if t.hasPosition && k.HasVelocity {
+		t.updateDive(d, k, prevTs)
+	}
+	return t.tricks
+}
+
+func (t *TrickDetector) updateRotation(d *Datagram, k *Kinematics, prevTs float32) {
+	dt, altitude := k.Interval, k.Altitude
+	pitchRate, rollRate := float64(d.Gyro[0]), float64(d.Gyro[1])
+	fast := math.Abs(pitchRate) >= trickRate || math.Abs(rollRate) >= trickRate
+	if !t.rotating {
+		if !fast {
+			return
+		}
+		t.rotating = true
+		t.start = prevTs
+		t.calm = -1
+		t.pitch, t.roll = 0, 0
+		t.startAlt, t.maxAlt = altitude, altitude
+		t.tumbling = false
+		t.startPitch, t.nose, t.inverted = 0, 0, false
+		if t.hasAttitude {
+			t.startPitch = d.EulerAngles().Pitch
+		}
+	}
+	t.pitch += pitchRate * dt
+	t.roll += rollRate * dt
+	t.maxAlt = math.Max(t.maxAlt, altitude)
+	if t.hasAttitude {
+		t.updateAttitude(d)
+	}
+	if math.Sqrt(sq(float64(d.Gyro[0]))+sq(float64(d.Gyro[1]))+sq(float64(d.Gyro[2]))) >= crashGyroSpike {
+		t.tumbling = true
+	}
+	if fast {
+		t.calm = -1
+		return
+	}
+	if t.calm < 0 {
+		t.calm = d.Timestamp
+	}
+	if d.Timestamp-t.calm < trickSettle {
+		return
+	}
+	t.rotating = false
+	if t.tumbling || float64(t.calm-t.start) > trickMaxTime {
+		return
+	}
+	if t.hasAttitude && (!t.inverted || d.UpsideDown()) {
+		// Rotation by gyro is not confirmed by attitude, e.g. drift or the drone did not come back upright
+		return
+	}
+	if trick, ok := t.classify(altitude); ok {
+		trick.Start, trick.End = t.start, t.calm
+		t.tricks = append(t.tricks, trick)
+	}
+}
+
+func (t *TrickDetector) updateAttitude(d *Datagram) {
+	if d.UpsideDown() {
+		t.inverted = true
+	}
+	if t.nose == 0 {
+		if change := d.EulerAngles().Pitch - t.startPitch; math.Abs(change) >= trickNosePitch {
+			t.nose = 1
+			if change < 0 {
+				t.nose = -1
+			}
+		}
+	}
+}
+
+// classify recognizes the finished rotation, false if it is not a trick
+func (t *TrickDetector) classify(altitude float64) (Trick, bool) {
+	pitch, roll := math.Abs(t.pitch), math.Abs(t.roll)
+	pitchTurns := int((pitch + trickTurnTolerance) / 360)
+	rollTurns := int((roll + trickTurnTolerance) / 360)
+	half := func(angle float64) bool { return math.Abs(angle-180) <= trickTurnTolerance }
+	gain := t.maxAlt - t.startAlt
+	drop := t.startAlt - altitude
+
+	switch {
+	case t.hasPosition && half(pitch) && half(roll) && drop >= trickLoopHeight:
+		return Trick{Kind: TrickSplitS, Height: drop, Points: trickPoints[TrickSplitS]}, true
+	case pitchTurns > 0 && roll < 90:
+		if pitchTurns == 1 && t.hasPosition && gain >= trickLoopHeight {
+			backward := t.pitch > 0
+			if t.hasAttitude {
+				backward = t.nose > 0
+			}
+			if backward {
+				return Trick{Kind: TrickPowerLoop, Height: gain, Points: trickPoints[TrickPowerLoop]}, true
+			}
+			return Trick{Kind: TrickMattyFlip, Height: gain, Points: trickPoints[TrickMattyFlip]}, true
+		}
+		return Trick{Kind: TrickFlip, Rotations: pitchTurns, Points: trickPoints[TrickFlip] * float64(pitchTurns)}, true
+	case rollTurns > 0 && pitch < 90:
+		return Trick{Kind: TrickRoll, Rotations: rollTurns, Points: trickPoints[TrickRoll] * float64(rollTurns)}, true
+	}
+	return Trick{}, false
+}
+
+func (t *TrickDetector) updateDive(d *Datagram, k *Kinematics, prevTs float32) {
+	if !t.diving {
+		if k.VerticalSpeed <= -trickDiveSpeed {
+			// Dive started since the previous datagram, its altitude is estimated back by the speed
+			t.diving = true
+			t.diveTs = prevTs
+			t.diveAlt = k.Altitude - k.VerticalSpeed*k.Interval
+			t.diveDrop = 0
+		}
+		return
+	}
+	if k.VerticalSpeed <= -trickDiveSpeed/2 {
+		t.diveDrop = t.diveAlt - k.Altitude
+		return
+	}
+	t.diving = false
+	if float64(prevTs-t.diveTs) >= trickDiveTime && t.diveDrop >= trickDiveDrop {
+		points := trickPoints[TrickDive] + t.diveDrop/20
+		t.tricks = append(t.tricks, Trick{Kind: TrickDive, Start: t.diveTs, End: prevTs, Height: t.diveDrop, Points: points})
+	}
+}
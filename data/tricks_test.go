@@ -0,0 +1,118 @@
+package lot_config_test
+
+import (
+	"math"
+	"slices"
+	"testing"
+
+	lot_config "github.com/dladlk/liftoff-telemetry/data"
+)
+
+// maneuver holds pitch and roll rates in degrees/second and climb in meters/second for its duration in seconds
+type maneuver struct {
+	duration  float64
+	pitchRate float32
+	rollRate  float32
+	climb     float32
+	drift     bool // Gyro reports the rates, but attitude does not change
+	flipped   bool // Gyro reports the rates with opposite signs
+}
+
+// multiply composes Unity quaternions, b is applied in the frame of a
+func multiply(a, b [4]float32) [4]float32 {
+	return [4]float32{
+		a[3]*b[0] + a[0]*b[3] + a[1]*b[2] - a[2]*b[1],
+		a[3]*b[1] - a[0]*b[2] + a[1]*b[3] + a[2]*b[0],
+		a[3]*b[2] + a[0]*b[1] - a[1]*b[0] + a[2]*b[3],
+		a[3]*b[3] - a[0]*b[0] - a[1]*b[1] - a[2]*b[2],
+	}
+}
+
+// fly returns tricks detected in the maneuvers flown between level flight
+func fly(maneuvers ...maneuver) []lot_config.Trick {
+	const dt = 0.01
+	fields := []lot_config.StreamDataType{lot_config.Timestamp, lot_config.Position, lot_config.Attitude, lot_config.Velocity, lot_config.Gyro}
+	calculator := lot_config.NewKinematicsCalculator(fields, lot_config.DefaultKinematicsSmoothing)
+	detector := lot_config.NewTrickDetector(fields)
+	var tricks []lot_config.Trick
+	d := lot_config.Datagram{Position: [3]float32{0, 20, 0}, Attitude: [4]float32{0, 0, 0, 1}}
+	level := maneuver{duration: 0.5}
+	for _, m := range slices.Concat([]maneuver{level}, maneuvers, []maneuver{level}) {
+		for i := 0; i < int(math.Round(m.duration/dt)); i++ {
+			d.Timestamp += dt
+			d.Position[1] += m.climb * dt
+			d.Velocity = [3]float32{0, m.climb, 10}
+			d.Gyro = [3]float32{m.pitchRate, m.rollRate, 0}
+			if !m.drift {
+				// Nose up is negative rotation around X and right wing down is negative rotation around Z in Unity frame
+				d.Attitude = multiply(d.Attitude, axisAngle([3]float64{1, 0, 0}, -float64(m.pitchRate)*dt))
+				d.Attitude = multiply(d.Attitude, axisAngle([3]float64{0, 0, 1}, -float64(m.rollRate)*dt))
+			}
+			if m.flipped {
+				d.Gyro = [3]float32{-m.pitchRate, -m.rollRate, 0}
+			}
+			kinematics := calculator.Update(&d)
+			tricks = append(tricks, detector.Update(&d, &kinematics)...)
+		}
+	}
+	return tricks
+}
+
+func TestTrickDetector_Update(t *testing.T) {
+	tests := []struct {
+		name          string
+		maneuvers     []maneuver
+		wantKinds     []lot_config.TrickKind
+		wantRotations int
+	}{
+		{name: "Double roll", maneuvers: []maneuver{{duration: 1, rollRate: 720}}, wantKinds: []lot_config.TrickKind{lot_config.TrickRoll}, wantRotations: 2},
+		{name: "Flip", maneuvers: []maneuver{{duration: 1, pitchRate: -360}}, wantKinds: []lot_config.TrickKind{lot_config.TrickFlip}, wantRotations: 1},
+		{name: "Power loop", maneuvers: []maneuver{{duration: 0.5, pitchRate: 360, climb: 10}, {duration: 0.5, pitchRate: 360, climb: -10}},
+			wantKinds: []lot_config.TrickKind{lot_config.TrickPowerLoop}},
+		{name: "Matty flip", maneuvers: []maneuver{{duration: 0.5, pitchRate: -360, climb: 10}, {duration: 0.5, pitchRate: -360, climb: -10}},
+			wantKinds: []lot_config.TrickKind{lot_config.TrickMattyFlip}},
+		{name: "Split-S", maneuvers: []maneuver{{duration: 0.5, rollRate: 360}, {duration: 0.5, pitchRate: 360, climb: -10}},
+			wantKinds: []lot_config.TrickKind{lot_config.TrickSplitS}},
+		{name: "Dive", maneuvers: []maneuver{{duration: 2, climb: -15}}, wantKinds: []lot_config.TrickKind{lot_config.TrickDive}},
+		{name: "Roll and flip", maneuvers: []maneuver{{duration: 0.5, rollRate: 720}, {duration: 0.5}, {duration: 0.5, pitchRate: 720}},
+			wantKinds: []lot_config.TrickKind{lot_config.TrickRoll, lot_config.TrickFlip}, wantRotations: 1},
+		{name: "Tumbling after a hit", maneuvers: []maneuver{{duration: 0.5, rollRate: 2500}}},
+		{name: "Slow turn", maneuvers: []maneuver{{duration: 2, pitchRate: 90}}},
+		{name: "Half roll", maneuvers: []maneuver{{duration: 0.5, rollRate: 360}}},
+		{name: "Gyro drift without rotation", maneuvers: []maneuver{{duration: 1, rollRate: 720, drift: true}}},
+		{name: "Power loop with opposite gyro sign", maneuvers: []maneuver{{duration: 0.5, pitchRate: 360, climb: 10, flipped: true},
+			{duration: 0.5, pitchRate: 360, climb: -10, flipped: true}}, wantKinds: []lot_config.TrickKind{lot_config.TrickPowerLoop}},
+		{name: "Flip ending upside down", maneuvers: []maneuver{{duration: 0.75, pitchRate: -720}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tricks := fly(tt.maneuvers...)
+			var kinds []lot_config.TrickKind
+			for _, trick := range tricks {
+				kinds = append(kinds, trick.Kind)
+				if trick.Start < 0.49 || trick.End <= trick.Start {
+					t.Errorf("Trick %v has wrong time", trick)
+				}
+				if tt.wantRotations > 0 && trick.Rotations != tt.wantRotations {
+					t.Errorf("Trick %v, want %d rotations", trick, tt.wantRotations)
+				}
+			}
+			if !slices.Equal(kinds, tt.wantKinds) {
+				t.Errorf("Tricks %v, want kinds %v", tricks, tt.wantKinds)
+			}
+		})
+	}
+}
+
+func TestStyleScore(t *testing.T) {
+	tricks := fly(maneuver{duration: 0.5, rollRate: 720}, maneuver{duration: 0.5},
+		maneuver{duration: 0.5, pitchRate: 360, climb: 10}, maneuver{duration: 0.5, pitchRate: 360, climb: -10}, maneuver{duration: 0.5},
+		maneuver{duration: 0.5, rollRate: -720})
+	// Two rolls and a power loop get a bonus for the second kind
+	if score := lot_config.StyleScore(tricks); math.Abs(score-(1+3+1)*1.25) > 1e-9 {
+		t.Errorf("StyleScore(%v) = %v, want 6.25", tricks, score)
+	}
+	if score := lot_config.StyleScore(nil); score != 0 {
+		t.Errorf("StyleScore(nil) = %v, want 0", score)
+	}
+}
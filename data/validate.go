@@ -0,0 +1,102 @@
+package lot_config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Endpoint Liftoff uses by default
+const DefaultEndpoint = "127.0.0.1:9001"
+
+// All stream formats supported by Liftoff in the order of Datagram fields
+var AllStreamFormatNames = []string{"Timestamp", "Position", "Attitude", "Velocity", "Gyro", "Input", "Battery", "MotorRPM"}
+
+// ConfigValidationError lists all problems which make the config unusable
+type ConfigValidationError struct {
+	Problems []string
+}
+
+func (e *ConfigValidationError) Error() string {
+	return fmt.Sprintf("invalid telemetry configuration: %s", strings.Join(e.Problems, "; "))
+}
+
+// Validate checks endpoint and stream format, required fields must be present in stream format.
+// Returns warnings for problems which do not prevent decoding and *ConfigValidationError for the rest.
+func (t LiftoffTelemetryConfig) Validate(required ...StreamDataType) (warnings []string, err error) {
+	var problems []string
+
+	if t.Endpoint == "" {
+		problems = append(problems, "endpoint is empty")
+	} else if _, _, splitErr := net.SplitHostPort(t.Endpoint); splitErr != nil {
+		problems = append(problems, fmt.Sprintf("endpoint '%s' is not host:port: %v", t.Endpoint, splitErr))
+	}
+
+	if len(t.StreamFormatNames) == 0 {
+		problems = append(problems, "stream format is empty")
+	}
+	seen := map[string]bool{}
+	for _, name := range t.StreamFormatNames {
+		if ParseStreamDataTypeFormats([]string{name})[0] == Unknown {
+			problem := fmt.Sprintf("unknown stream format '%s'", name)
+			for _, known := range AllStreamFormatNames {
+				if strings.EqualFold(known, strings.TrimSpace(name)) {
+					problem += fmt.Sprintf(", did you mean '%s'?", known)
+				}
+			}
+			problems = append(problems, problem)
+			continue
+		}
+		if seen[name] {
+			warnings = append(warnings, fmt.Sprintf("stream format '%s' is listed more than once", name))
+		}
+		seen[name] = true
+	}
+	for _, field := range required {
+		if !seen[field.String()] {
+			problems = append(problems, fmt.Sprintf("required stream format '%s' is missing", field))
+		}
+	}
+
+	if len(problems) > 0 {
+		return warnings, &ConfigValidationError{Problems: problems}
+	}
+	return warnings, nil
+}
+
+// WriteLiftoffTelemetryConfig validates the config and writes it to path in Liftoff format.
+// Existing file is copied first to a backup file with timestamp suffix, which path is returned.
+func WriteLiftoffTelemetryConfig(path string, config *LiftoffTelemetryConfig) (backupPath string, err error) {
+	if _, err := config.Validate(); err != nil {
+		return "", err
+	}
+	content, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	original, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		backupPath = fmt.Sprintf("%s.%s.bak", path, time.Now().Format("20060102_150405"))
+		if err := os.WriteFile(backupPath, original, 0666); err != nil {
+			return "", fmt.Errorf("Failed to back up %s: %w", path, err)
+		}
+	case errors.Is(err, os.ErrNotExist):
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return "", err
+		}
+	default:
+		return "", err
+	}
+
+	if err := os.WriteFile(path, content, 0666); err != nil {
+		return backupPath, err
+	}
+	return backupPath, nil
+}
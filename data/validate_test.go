@@ -0,0 +1,81 @@
+package lot_config_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	lot_config "github.com/dladlk/liftoff-telemetry/data"
+)
+
+func TestLiftoffTelemetryConfig_Validate(t *testing.T) {
+	required := []lot_config.StreamDataType{lot_config.Timestamp, lot_config.Position}
+
+	tests := []struct {
+		name         string
+		endpoint     string
+		formats      []string
+		wantWarnings int
+		wantProblem  string
+	}{
+		{name: "Valid", endpoint: "127.0.0.1:9001", formats: []string{"Timestamp", "Position", "MotorRPM"}},
+		{name: "Duplicate", endpoint: "127.0.0.1:9001", formats: []string{"Timestamp", "Position", "Position"}, wantWarnings: 1},
+		{name: "Empty endpoint", endpoint: "", formats: []string{"Timestamp", "Position"}, wantProblem: "endpoint is empty"},
+		{name: "Endpoint without port", endpoint: "127.0.0.1", formats: []string{"Timestamp", "Position"}, wantProblem: "not host:port"},
+		{name: "Unknown field", endpoint: "127.0.0.1:9001", formats: []string{"Timestamp", "Position", "gyro"}, wantProblem: "did you mean 'Gyro'"},
+		{name: "Missing position", endpoint: "127.0.0.1:9001", formats: []string{"Timestamp"}, wantProblem: "'Position' is missing"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := lot_config.NewLiftoffTelemetryConfig(tt.endpoint, tt.formats)
+			warnings, err := config.Validate(required...)
+			if len(warnings) != tt.wantWarnings {
+				t.Errorf("Validate() warnings = %v, want %d", warnings, tt.wantWarnings)
+			}
+			if tt.wantProblem == "" {
+				if err != nil {
+					t.Errorf("Validate() failed: %v", err)
+				}
+				return
+			}
+			var validationErr *lot_config.ConfigValidationError
+			if !errors.As(err, &validationErr) || !strings.Contains(err.Error(), tt.wantProblem) {
+				t.Errorf("Validate() = %v, want problem '%s'", err, tt.wantProblem)
+			}
+		})
+	}
+}
+
+func TestWriteLiftoffTelemetryConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "TelemetryConfiguration.json")
+	original := []byte(`{"EndPoint": "", "StreamFormat": ["Unknown"]}`)
+	if err := os.WriteFile(path, original, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := lot_config.WriteLiftoffTelemetryConfig(path, lot_config.NewLiftoffTelemetryConfig("", nil)); err == nil {
+		t.Fatal("WriteLiftoffTelemetryConfig() succeeded with invalid config")
+	}
+
+	config := lot_config.NewLiftoffTelemetryConfig(lot_config.DefaultEndpoint, lot_config.AllStreamFormatNames)
+	backupPath, err := lot_config.WriteLiftoffTelemetryConfig(path, config)
+	if err != nil {
+		t.Fatalf("WriteLiftoffTelemetryConfig() failed: %v", err)
+	}
+	if backup, err := os.ReadFile(backupPath); err != nil || string(backup) != string(original) {
+		t.Errorf("Backup %s = %s, %v, want original content", backupPath, backup, err)
+	}
+
+	written, err := lot_config.ReadLiftoffTelemetryConfigFrom(path)
+	if err != nil {
+		t.Fatalf("ReadLiftoffTelemetryConfigFrom() failed: %v", err)
+	}
+	if written.Endpoint != config.Endpoint || len(written.StreamFormats) != len(lot_config.AllStreamFormatNames) {
+		t.Errorf("Written config = %+v, want %+v", written, config)
+	}
+	if _, err := written.Validate(lot_config.Timestamp, lot_config.Position); err != nil {
+		t.Errorf("Written config is invalid: %v", err)
+	}
+}
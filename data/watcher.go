@@ -0,0 +1,56 @@
+package lot_config
+
+import (
+	"os"
+	"time"
+)
+
+// How often ConfigWatcher checks TelemetryConfiguration.json by default
+const DefaultWatchInterval = 2 * time.Second
+
+// ConfigWatcher detects changes of TelemetryConfiguration.json by polling its modification time and size
+type ConfigWatcher struct {
+	path      string
+	interval  time.Duration
+	modTime   time.Time
+	size      int64
+	lastCheck time.Time
+}
+
+// NewConfigWatcher starts watching the file the config was read from. Returns nil for inline config without file.
+func NewConfigWatcher(config *LiftoffTelemetryConfig, interval time.Duration) *ConfigWatcher {
+	if config.Path == "" {
+		return nil
+	}
+	w := &ConfigWatcher{path: config.Path, interval: interval, lastCheck: time.Now()}
+	if info, err := os.Stat(config.Path); err == nil {
+		w.modTime = info.ModTime()
+		w.size = info.Size()
+	}
+	return w
+}
+
+// Poll checks the file at most once per interval and re-reads it if it was changed since the last successful read.
+// Returns nil config if nothing changed. Nil watcher never reports changes.
+func (w *ConfigWatcher) Poll() (*LiftoffTelemetryConfig, error) {
+	if w == nil || time.Since(w.lastCheck) < w.interval {
+		return nil, nil
+	}
+	w.lastCheck = time.Now()
+
+	info, err := os.Stat(w.path)
+	if err != nil {
+		return nil, err
+	}
+	if info.ModTime().Equal(w.modTime) && info.Size() == w.size {
+		return nil, nil
+	}
+	config, err := ReadLiftoffTelemetryConfigFrom(w.path)
+	if err != nil {
+		// Liftoff may be in the middle of writing the file, try again on next poll
+		return nil, err
+	}
+	w.modTime = info.ModTime()
+	w.size = info.Size()
+	return config, nil
+}
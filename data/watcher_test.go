@@ -0,0 +1,46 @@
+package lot_config_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	lot_config "github.com/dladlk/liftoff-telemetry/data"
+)
+
+func TestConfigWatcher_Poll(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "TelemetryConfiguration.json")
+	write := func(content string, modTime time.Time) {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	start := time.Now().Add(-time.Hour)
+	write(`{"EndPoint": "127.0.0.1:9001", "StreamFormat": ["Timestamp"]}`, start)
+
+	config, err := lot_config.ReadLiftoffTelemetryConfigFrom(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lot_config.NewConfigWatcher(lot_config.NewLiftoffTelemetryConfig("127.0.0.1:9001", nil), 0) != nil {
+		t.Error("NewConfigWatcher() watches inline config")
+	}
+	watcher := lot_config.NewConfigWatcher(config, 0)
+
+	if changed, err := watcher.Poll(); changed != nil || err != nil {
+		t.Fatalf("Poll() = %v, %v before change", changed, err)
+	}
+
+	write(`{"EndPoint": "127.0.0.1:9002", "StreamFormat": ["Timestamp", "Position"]}`, start.Add(time.Minute))
+	changed, err := watcher.Poll()
+	if err != nil || changed == nil || changed.Endpoint != "127.0.0.1:9002" || !changed.HasPosition() {
+		t.Fatalf("Poll() = %v, %v after change", changed, err)
+	}
+	if changed, err := watcher.Poll(); changed != nil || err != nil {
+		t.Errorf("Poll() = %v, %v reports the same change twice", changed, err)
+	}
+}
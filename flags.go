@@ -0,0 +1,108 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"strings"
+)
+
+// cliFlags are command line options of the recorder, explicitly set ones override values of the config file
+type cliFlags struct {
+	set *flag.FlagSet
+
+	configPath      string
+	printConfig     bool
+	telemetryConfig string
+	outputDir       string
+	fileName        string
+	format          string
+	saveEachNth     int
+	endpoint        string
+	streamFormat    string
+	debug           bool
+	track           string
+	drone           string
+	logFile         string
+}
+
+func newCliFlags(name string) *cliFlags {
+	f := &cliFlags{set: flag.NewFlagSet(name, flag.ExitOnError)}
+	f.set.StringVar(&f.configPath, "config", DEFAULT_CONFIG_PATH, "Path to app config file")
+	f.set.BoolVar(&f.printConfig, "print-config", false, "Print effective configuration merged from defaults, config file and flags, and exit")
+	f.set.StringVar(&f.telemetryConfig, "telemetry-config", "", "Path to Liftoff TelemetryConfiguration.json, overrides discovery")
+	f.set.StringVar(&f.outputDir, "output-dir", "", "Directory to write recorded files to, current one by default")
+	f.set.StringVar(&f.fileName, "file-name", DEFAULT_FILE_NAME, "Template of recorded file names without extension, {time} and {format} are replaced")
+	f.set.StringVar(&f.format, "format", "csv", "Format of recorded files: csv or bin")
+	f.set.IntVar(&f.saveEachNth, "save-each-nth", 0, "Record only each Nth packet, all if 0")
+	f.set.StringVar(&f.endpoint, "endpoint", "", "Endpoint to listen, overrides Liftoff telemetry config")
+	f.set.StringVar(&f.streamFormat, "stream-format", "", "Comma separated stream formats, overrides Liftoff telemetry config")
+	f.set.StringVar(&f.track, "track", "", "Name of the track from config to time laps on, found by spawn position by default")
+	f.set.StringVar(&f.drone, "drone", "", "Name of the drone to keep personal bests for")
+	f.set.BoolVar(&f.debug, "debug", false, "Log every received packet")
+	f.set.StringVar(&f.logFile, "log-file", "", "Write log to this file too, <executable>.log by default if logToFile is set")
+	return f
+}
+
+// loadConfig parses command line and merges defaults, config file and flags, in increasing priority.
+// Missing default config file is not an error, defaults are used then.
+func (f *cliFlags) loadConfig(args []string) (*Config, error) {
+	f.set.Parse(args)
+
+	explicit := map[string]bool{}
+	f.set.Visit(func(fl *flag.Flag) {
+		explicit[fl.Name] = true
+	})
+
+	config, err := LoadConfig(f.configPath)
+	if err != nil {
+		if !explicit["config"] && errors.Is(err, fs.ErrNotExist) {
+			log.Printf("App config file %s is not found, using defaults", f.configPath)
+			config = DefaultConfig()
+		} else {
+			return nil, fmt.Errorf("Failed to read app config file %s: %w", f.configPath, err)
+		}
+	}
+
+	for name := range explicit {
+		switch name {
+		case "telemetry-config":
+			config.Liftoff.TelemetryConfig = f.telemetryConfig
+		case "output-dir":
+			config.Output.Dir = f.outputDir
+		case "file-name":
+			config.Output.FileName = f.fileName
+		case "format":
+			config.General.Format = f.format
+		case "save-each-nth":
+			config.General.SaveEachNth = int32(f.saveEachNth)
+		case "endpoint":
+			config.Liftoff.Endpoint = f.endpoint
+		case "stream-format":
+			config.Liftoff.StreamFormat = splitNames(f.streamFormat)
+		case "track":
+			config.General.Track = f.track
+		case "drone":
+			config.General.Drone = f.drone
+		case "debug":
+			config.Log.Debug = f.debug
+		case "log-file":
+			config.Log.LogToFile = true
+			config.Log.File = f.logFile
+		}
+	}
+	return config, config.Validate()
+}
+
+// splitNames splits comma separated list, skipping empty items
+func splitNames(s string) []string {
+	var names []string
+	for _, name := range strings.Split(s, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
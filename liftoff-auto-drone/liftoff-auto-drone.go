@@ -61,6 +61,7 @@ func main() {
 	}
 	doHelp := flag.Bool("help", false, "Prints help")
 	doDryRun := flag.Bool("dry-run", false, "Dry-run - without starting joystick simulation")
+	telemetryConfigPath := flag.String("telemetry-config", "", "Path to Liftoff TelemetryConfiguration.json, overrides discovery")
 
 	flag.Parse()
 	if *doHelp {
@@ -70,7 +71,7 @@ func main() {
 
 	noopDrone := *doDryRun
 
-	telemetryListener := TelemetryListener{}
+	telemetryListener := TelemetryListener{configPath: *telemetryConfigPath}
 
 	left := Joystick{}
 	right := Joystick{}
@@ -1,11 +1,13 @@
 package main
 
 import (
-	"bufio"
+	"errors"
 	"fmt"
+	"io"
 	"os"
-	"strconv"
-	"strings"
+	"slices"
+
+	lot_config "github.com/dladlk/liftoff-telemetry/data"
 )
 
 type Telemetry struct {
@@ -32,51 +34,25 @@ func ReadTelemetry(path string) (*Telemetry, error) {
 	}
 	defer file.Close()
 
-	scanner := bufio.NewScanner(file)
+	reader, err := lot_config.NewCsvReader(file)
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Contains(reader.Fields(), lot_config.Input) {
+		return nil, fmt.Errorf("Telemetry %s has no Input columns", path)
+	}
 
 	telemetry := Telemetry{Name: path}
 
-	lineIndex := -1
-	for scanner.Scan() {
-		lineIndex++
-		line := scanner.Text()
-		line = strings.TrimSpace(line)
-		if strings.HasPrefix(line, "#") {
-			// Skip comments
-			continue
-		}
-		if len(line) == 0 {
-			continue
-		}
-		parts := strings.Split(line, ",")
-		if len(parts) != 9 {
-			return nil, fmt.Errorf("Line %d is invalid, expected 9 values separated by COMMA, but found: %s", lineIndex, line)
-		}
-
-		timestamp, err := strconv.ParseFloat(parts[2], 32)
-		if err != nil {
-			return nil, fmt.Errorf("Line %d is invalid, value %d is not a valid float32: %s", lineIndex, 2, parts[2])
-		}
-
-		inputValue := [4]float32{}
-
-		trimmed := strings.Trim(parts[7], "[]")
-		inputParts := strings.Split(trimmed, " ")
-
-		for i := range inputValue {
-			val, err := strconv.ParseFloat(inputParts[i], 32)
-			if err != nil {
-				return nil, fmt.Errorf("Line %d is invalid, value of inputs at index %d is not a valid integer: %s", lineIndex, (i + 1), parts[7])
+	var d lot_config.Datagram
+	for {
+		if _, _, err := reader.Next(&d); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
 			}
-			inputValue[i] = float32(val)
+			return nil, err
 		}
-		telemetry.Add(inputValue, float32(timestamp))
-	}
-
-	// Check for errors during scanning
-	if err := scanner.Err(); err != nil {
-		fmt.Println("Error reading file:", err)
-		return nil, err
+		telemetry.Add(d.Input, d.Timestamp)
 	}
 
 	return &telemetry, nil
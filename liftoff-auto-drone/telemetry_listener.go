@@ -1,97 +1,93 @@
 package main
 
 import (
-	"bytes"
+	"context"
 	"fmt"
 	"log"
-	"net"
 	"sync"
 
 	lot_config "github.com/dladlk/liftoff-telemetry/data"
+	lot_source "github.com/dladlk/liftoff-telemetry/source"
 )
 
 type TelemetryListener struct {
-	running        bool
-	lotConfig      lot_config.LiftoffTelemetryConfig
-	conn           *net.UDPConn
-	expected       int
-	lastBytes      []byte
-	lastBytesIndex int
-	mu             sync.Mutex
+	running    bool
+	configPath string
+	source     *lot_source.Source
+	cancel     context.CancelFunc
+	last       lot_config.Datagram
+	lastIndex  int
+	mu         sync.Mutex
 }
 
 func (t *TelemetryListener) Toggle() {
 	if !t.running {
-		lotConfig, err := lot_config.ReadLiftoffTelemetryConfig()
+		lotConfig, err := lot_config.ReadLiftoffTelemetryConfigFrom(t.configPath)
 		if err != nil {
 			log.Fatalf("Failed to read telemetry configuration: %v", err)
 		}
-		t.lotConfig = *lotConfig
-
-		address, err := net.ResolveUDPAddr("udp", lotConfig.Endpoint)
-		if err != nil {
-			log.Fatal("Error resolving UDP address:", err)
+		warnings, err := lotConfig.Validate()
+		for _, warning := range warnings {
+			fmt.Printf("\r\nWarning: %s", warning)
 		}
-		conn, err := net.ListenUDP("udp", address)
 		if err != nil {
-			log.Fatal("Error listening: ", err)
+			log.Fatalf("Invalid telemetry configuration %s: %v", lotConfig.Path, err)
 		}
-		t.conn = conn
 
-		fmt.Printf("\r\nStarted telemetry listener on %v by config %+v\n", lotConfig.Endpoint, lotConfig)
+		t.source = lot_source.New(lotConfig)
+		t.source.Logf = func(format string, v ...any) {
+			fmt.Printf("\r\n"+format, v...)
+		}
+		t.source.Validate = func(lotConfig *lot_config.LiftoffTelemetryConfig) error {
+			_, err := lotConfig.Validate()
+			return err
+		}
+		segmenter := lot_config.NewSessionSegmenter(lotConfig.StreamFormats)
+		segmenterConfig := lotConfig
+		t.source.SubscribeFunc(func(packet *lot_source.Packet) {
+			if packet.Config != segmenterConfig {
+				// Telemetry config is reloaded, the race goes on with the new fields
+				segmenterConfig = packet.Config
+				segmenter.SetFields(segmenterConfig.StreamFormats)
+			}
+			for _, event := range segmenter.Update(&packet.Datagram) {
+				fmt.Printf("\r\nRace %v", event)
+			}
+			t.mu.Lock()
+			packet.Datagram.CopyTo(&t.last)
+			t.lastIndex++
+			t.mu.Unlock()
+		})
 
-		expectedBlockLength := int(lot_config.CalculateBlockLength(lotConfig.StreamFormats))
-		t.expected = expectedBlockLength
+		ctx, cancel := context.WithCancel(context.Background())
+		t.cancel = cancel
 		t.running = true
 
-		go func() {
-			buffer := make([]byte, expectedBlockLength)
-
-			for {
-				if !t.running {
-					break
-				}
-				n, clientAddr, err := conn.ReadFromUDP(buffer)
-				if err != nil {
-					log.Fatalf("Read error from %s: %v\n", clientAddr, err)
-				}
-				if n == expectedBlockLength {
-					copiedBytes := make([]byte, expectedBlockLength)
-					copied := copy(copiedBytes, buffer)
-					if copied != n {
-						log.Fatalf("Expected to copy %d, but copied only %d", n, copied)
-					}
+		fmt.Printf("\r\nStarted telemetry listener on %v by config %+v\n", lotConfig.Endpoint, lotConfig)
 
-					t.mu.Lock()
-					t.lastBytes = copiedBytes
-					t.lastBytesIndex++
-					t.mu.Unlock()
-				} else {
-					log.Fatalf("Unexpected block length %d, expected %d", n, expectedBlockLength)
-				}
+		go func() {
+			if err := t.source.Run(ctx); err != nil {
+				log.Fatal("Error listening: ", err)
 			}
-
 		}()
 	} else {
 		t.running = false
-		t.conn.Close()
-		t.lastBytes = nil
-		t.lastBytesIndex = 0
-		fmt.Printf("\r\nStopped telemetry listener\n")
+		t.cancel()
+		fmt.Printf("\r\nStopped telemetry listener, packets %v\n", t.source.Stats())
+		t.mu.Lock()
+		t.lastIndex = 0
+		t.mu.Unlock()
 	}
 }
 
 func (t *TelemetryListener) LastDatagram() (*lot_config.Datagram, int, bool) {
 	if t.running {
-		if t.lastBytesIndex > 0 {
-			var lastBytes []byte = make([]byte, t.expected)
-			t.mu.Lock()
-			index := t.lastBytesIndex
-			copy(lastBytes, t.lastBytes)
-			t.mu.Unlock()
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		if t.lastIndex > 0 {
 			res := &lot_config.Datagram{}
-			res.ParseDatagram(bytes.NewReader(lastBytes), &t.lotConfig.StreamFormats)
-			return res, index, true
+			t.last.CopyTo(res)
+			return res, t.lastIndex, true
 		}
 	}
 	return nil, 0, false
@@ -2,7 +2,6 @@ package main
 
 import (
 	"bufio"
-	"bytes"
 	"errors"
 	"fmt"
 	"io"
@@ -36,34 +35,44 @@ func (t *Track) Open(path string) error {
 	header = strings.TrimSpace(header)
 	t.fields = lot_config.ParseStreamDataTypeFormats(strings.Split(header, ","))
 
-	blockLength := lot_config.CalculateBlockLength(t.fields)
+	minBlockLength := lot_config.MinBlockLength(t.fields)
 
-	fmt.Printf("File header: %s, block length: %d\r\n", header, blockLength)
+	fmt.Printf("File header: %s, min block length: %d\r\n", header, minBlockLength)
 
-	buffer := make([]byte, blockLength)
+	var buffer []byte
+	decoder := lot_config.NewDecoder(t.fields)
 
 	blocks := 0
+	skipped := 0
 
 	for {
-		n, err := io.ReadFull(reader, buffer)
+		buffer, err = lot_config.ReadBlock(reader, t.fields, buffer)
 		if err != nil {
-			if err.Error() == "EOF" {
+			if err == io.EOF {
 				break
 			}
-			return err
+			if errors.Is(err, lot_config.ErrTruncated) {
+				fmt.Printf("Skip truncated tail of %d bytes after block %d: %v\r\n", len(buffer), blocks+skipped, err)
+				break
+			}
+			return fmt.Errorf("Failed to read block %d: %w", blocks+skipped+1, err)
 		}
-		if n != int(blockLength) {
-			return fmt.Errorf("Expected to read %d bytes, but read only %d", blockLength, n)
+		datagram := lot_config.Datagram{}
+		if err := decoder.Decode(buffer, &datagram); err != nil {
+			skipped++
+			fmt.Printf("Skip malformed block %d: %v\r\n", blocks+skipped, err)
+			continue
 		}
 		blocks++
-		datagram := lot_config.Datagram{}
-		datagram.ParseDatagram(bytes.NewReader(buffer[:n]), &t.fields)
 
 		t.List = append(t.List, datagram)
 	}
+	if len(t.List) == 0 {
+		return fmt.Errorf("No valid blocks found in %s", path)
+	}
 	t.minTs = t.List[0].Timestamp
 	t.maxTs = t.List[len(t.List)-1].Timestamp
-	fmt.Printf("Loaded %d blocks, min ts %.2f sec, max ts %.2f sec\n", blocks, t.minTs, t.maxTs)
+	fmt.Printf("Loaded %d blocks, skipped %d, min ts %.2f sec, max ts %.2f sec\n", blocks, skipped, t.minTs, t.maxTs)
 
 	return nil
 }
@@ -1,3 +1,7 @@
 module github.com/dladlk/liftoff-map
 
 go 1.25.6
+
+require github.com/dladlk/liftoff-telemetry v0.0.0-00010101000000-000000000000
+
+replace github.com/dladlk/liftoff-telemetry => ..
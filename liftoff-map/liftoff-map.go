@@ -1,7 +1,6 @@
 package main
 
 import (
-	"encoding/csv"
 	"fmt"
 	"image"
 	"image/color"
@@ -13,8 +12,8 @@ import (
 	"os"
 	"os/exec"
 	"runtime"
-	"strconv"
-	"strings"
+
+	lot_config "github.com/dladlk/liftoff-telemetry/data"
 )
 
 type MinMax struct {
@@ -40,17 +39,29 @@ func main() {
 	// Ensure the file is closed when the function returns.
 	defer f.Close()
 
-	// 2. Create a new CSV reader using the opened file.
-	csvReader := csv.NewReader(f)
+	// 2. Create a new CSV reader using the opened file, columns are found by its header
+	csvReader, err := lot_config.NewCsvReader(f)
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	xMinMax := MinMax{min: math.MaxFloat32}
 	yMinMax := MinMax{min: math.MaxFloat32}
 
+	fields := csvReader.Fields()
+	calculator := lot_config.NewKinematicsCalculator(fields, lot_config.DefaultKinematicsSmoothing)
+	detector := lot_config.NewCrashDetector(fields)
+	var crashes []lot_config.Crash
+	var maxSpeed float64
+	var maxGLoad float64
+
 	var path [][]float32
+	var speeds []float64
+	var cur lot_config.Datagram
 	// 3. Loop indefinitely, reading one record at a time.
 	for {
-		// Read one record (a slice of strings) from the CSV file.
-		rec, err := csvReader.Read()
+		// Read one record into the datagram from the CSV file.
+		_, _, err := csvReader.Next(&cur)
 
 		// Check if the end of the file has been reached.
 		if err == io.EOF {
@@ -61,25 +72,33 @@ func main() {
 			log.Fatal(err)
 		}
 
-		// 4. Process the read line (record).
-		// Each record is a slice of strings, where each element is a field.
-		//fmt.Printf("Record: %v\n", rec[3])
-		position := strings.Split(rec[3][1:len(rec[3])-1], " ")
-		x, _ := strconv.ParseFloat(position[0], 32)
-		y, _ := strconv.ParseFloat(position[2], 32)
 		//fmt.Printf("%.5f - %.5f\n", x, y)
-		row := []float32{float32(x), float32(y)}
+		row := []float32{cur.Position[0], cur.Position[2]}
 
 		if row[0] == 0 && row[1] == 0 {
 			continue
 		}
 
+		kinematics := calculator.Update(&cur)
+		maxSpeed = max(maxSpeed, kinematics.Speed)
+		if kinematics.HasAcceleration {
+			maxGLoad = max(maxGLoad, kinematics.GLoad)
+		}
+
+		if crash, ok := detector.Update(&cur, &kinematics); ok {
+			crashes = append(crashes, crash)
+		}
+
 		path = append(path, row)
+		speeds = append(speeds, kinematics.Speed)
 		xMinMax.add(row[0])
 		yMinMax.add(row[1])
 	}
 
-	log.Printf("Loaded %d rows, x MinMax %+v, y MinMax %+v", len(path), xMinMax, yMinMax)
+	log.Printf("Loaded %d rows, x MinMax %+v, y MinMax %+v, max speed %.2f m/s, max g-load %.1f g, %d crashes", len(path), xMinMax, yMinMax, maxSpeed, maxGLoad, len(crashes))
+	for _, crash := range crashes {
+		log.Printf("Crash: %v", crash)
+	}
 
 	const padding = 10
 	const scale = 5
@@ -101,13 +120,11 @@ func main() {
 	draw.Draw(dst, dst.Bounds(), &image.Uniform{C: white}, image.Point{X: 0, Y: 0}, draw.Src)
 
 	startSrc := &image.Uniform{C: color.RGBA{R: 0, G: 255, B: 0, A: 255}}
-	tripSrc := &image.Uniform{C: color.RGBA{R: 0, G: 0, B: 255, A: 255}}
 	endSrc := &image.Uniform{C: color.RGBA{R: 255, G: 0, B: 0, A: 255}}
 
 	var src *image.Uniform
 
-	for i := range path {
-		pos := path[i]
+	toCanvas := func(pos []float32) []int {
 		con := []int{int(pos[0]-xMinMax.min)*scale + padding, int(pos[1]-yMinMax.min)*scale + padding}
 		if flipX {
 			con[0] = width - con[0]
@@ -115,6 +132,11 @@ func main() {
 		if flipY {
 			con[1] = height - con[1]
 		}
+		return con
+	}
+
+	for i := range path {
+		con := toCanvas(path[i])
 
 		// fmt.Printf("%v %v\n", con[0], con[1])
 		// Draw the blue square at (50, 50) on the destination image
@@ -135,12 +157,20 @@ func main() {
 		case i > len(path)-10:
 			src = endSrc
 		default:
-			src = tripSrc
+			src = speedSrc(speeds[i], maxSpeed)
 		}
 
 		draw.Draw(dst, drawRect, src, image.Point{X: 0, Y: 0}, draw.Over)
 	}
 
+	// Crashes are drawn over the path, bigger for more severe ones
+	crashSrc := &image.Uniform{C: color.RGBA{R: 0, G: 0, B: 0, A: 255}}
+	for _, crash := range crashes {
+		con := toCanvas([]float32{crash.Position[0], crash.Position[2]})
+		size := 3 + 2*int(crash.Severity)
+		draw.Draw(dst, image.Rect(con[0]-size, con[1]-size, con[0]+size, con[1]+size), crashSrc, image.Point{X: 0, Y: 0}, draw.Over)
+	}
+
 	// 5. Save the result to a file (e.g., PNG)
 	outputFile, err := os.Create("path.png")
 	if err != nil {
@@ -155,6 +185,15 @@ func main() {
 
 }
 
+// speedSrc colors trip from blue when slow to magenta at max speed
+func speedSrc(speed float64, maxSpeed float64) *image.Uniform {
+	ratio := 0.0
+	if maxSpeed > 0 {
+		ratio = speed / maxSpeed
+	}
+	return &image.Uniform{C: color.RGBA{R: uint8(255 * ratio), G: 0, B: 255, A: 255}}
+}
+
 func openFile(outputFile *os.File) {
 	url := outputFile.Name()
 	var cmd *exec.Cmd
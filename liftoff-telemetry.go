@@ -1,54 +1,59 @@
 package main
 
 import (
-	"bytes"
+	"context"
 	"io"
 	"log"
-	"net"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
 	lot_config "github.com/dladlk/liftoff-telemetry/data"
+	lot_source "github.com/dladlk/liftoff-telemetry/source"
+	"github.com/pelletier/go-toml/v2"
 )
 
-const CIRCLE_DISTANCE_TO_START = 3
+const VERSION = "0.0.1"
 
-type Trip struct {
-	Type            string
-	Start           time.Time
-	End             time.Time
-	DurationSeconds int
-	Events          int32
-	Index           int
-	MaxDistance     float64
-	MaxVelocity     float32
-	TripDistance    float64
-}
+const CIRCLE_DISTANCE_TO_START = 3
 
-func (this *Trip) Report() {
-	this.End = time.Now()
-	duration := this.End.Sub(this.Start)
-	this.DurationSeconds = int(duration.Seconds())
-	log.Printf("%s #%d: %v (%ds), %d events, total %.1f, max velocity: %.2f m/s, max from start: %.1f",
-		this.Type, this.Index, duration.Round(time.Second), this.DurationSeconds, this.Events, this.TripDistance, this.MaxVelocity, this.MaxDistance)
+func main() {
+	os.Exit(run())
 }
 
-func main() {
+// run records telemetry until interrupted and returns exit status. Deferred calls are done before exit.
+func run() int {
 	log.SetPrefix("")
 	log.SetFlags(log.Ltime | log.Ldate)
 
-	config, err := LoadConfig("liftoff-telemetry.toml.ini")
+	if len(os.Args) > 1 && os.Args[1] == "write-config" {
+		runWriteConfig(os.Args[2:])
+	}
+	if len(os.Args) > 1 && os.Args[1] == "replay" {
+		return runReplay(os.Args[2:])
+	}
+	if len(os.Args) > 1 && os.Args[1] == "records" {
+		return runRecords(os.Args[2:])
+	}
+
+	cli := newCliFlags(os.Args[0])
+	config, err := cli.loadConfig(os.Args[1:])
 	if err != nil {
-		log.Fatalf("Failed to read app config file liftoff-telemetry.toml.ini: %v", err)
+		log.Fatalf("%v", err)
+	}
+	if cli.printConfig {
+		b, err := toml.Marshal(config)
+		if err != nil {
+			log.Fatalf("Failed to print config: %v", err)
+		}
+		os.Stdout.Write(b)
+		return 0
 	}
 	log.Printf("Liftoff Telemetry Listener config: %+v", config)
 
-	debug := config.Log.Debug
-
 	if config.Log.LogToFile {
-		logFile, err := os.OpenFile(os.Args[0]+".log", os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0666)
+		logFile, err := os.OpenFile(config.Log.File, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0666)
 		if err != nil {
 			log.Fatalf("error opening log file: %v", err)
 		}
@@ -57,155 +62,84 @@ func main() {
 		log.SetOutput(multiWriter)
 	}
 
-	var curSession Trip
-	var curCircle Trip
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
 
 	// Create a channel to receive OS signals
-	signalChan := make(chan os.Signal, 1)
+	signalChan := make(chan os.Signal, 2)
 	// Notify the channel of SIGINT (Ctrl+C) and SIGTERM signals
 	signal.Notify(signalChan, os.Interrupt, syscall.SIGTERM)
 
-	// Start a goroutine to handle the signal
+	// First signal stops reading telemetry, so current session is finished and written, second one exits immediately
 	go func() {
-		<-signalChan // Block until a signal is received
-		curSession.Report()
-		os.Exit(0) // Exit gracefully after the command finishes
+		<-signalChan
+		log.Printf("Stopping, press Ctrl+C again to exit immediately")
+		stop()
+		<-signalChan
+		log.Printf("Forced exit, current recording may be incomplete")
+		os.Exit(130)
 	}()
 
-	lotConfig, err := lot_config.ReadLiftoffTelemetryConfig()
+	lotConfig, err := config.LiftoffTelemetryConfig()
 	if err != nil {
 		log.Fatalf("Failed to read telemetry configuration: %v", err)
 	}
 	log.Printf("Found Liftoff Telemetry Config: %+v \n", lotConfig)
-
-	address, err := net.ResolveUDPAddr("udp", lotConfig.Endpoint)
-	if err != nil {
-		log.Fatal("Error resolving UDP address:", err)
-	}
-	conn, err := net.ListenUDP("udp", address)
-	if err != nil {
-		log.Fatal("Error listening: ", err)
+	if err := validateLiftoffConfig(lotConfig); err != nil {
+		log.Fatalf("%v\nRun '%s write-config' to write a valid one", err, os.Args[0])
 	}
-	defer conn.Close() // Ensure the connection is closed when the function exits
-
-	log.Printf("Liftoff Telemetry UDP server listening on %s\n", conn.LocalAddr().String())
-
-	writer := Writer{}
-	writer.Start(config, lotConfig)
-	defer writer.Close()
-
-	buffer := make([]byte, 1024)
-	var prev *lot_config.Datagram
 
-	curSession = Trip{Type: "Race", Start: time.Now(), Index: 1}
-	curCircle = Trip{Type: "Circle", Start: time.Now(), Index: 1}
-	defer curSession.Report()
-	curSessionReported := false
-	var firstEvent *lot_config.Datagram = nil
+	telemetrySource := lot_source.New(lotConfig)
+	telemetrySource.Validate = validateLiftoffConfig
 
-	expectedBlockLength := int(lot_config.CalculateBlockLength(lotConfig.StreamFormats))
-
-	for {
-		n, clientAddr, err := conn.ReadFromUDP(buffer)
+	var relay *lot_source.Relay
+	if len(config.Relay.Targets) > 0 {
+		relay, err = lot_source.NewRelay(config.Relay.Targets)
 		if err != nil {
-			log.Printf("Read error from %s: %v\n", clientAddr, err)
-			continue
+			log.Fatalf("Failed to start relay: %v", err)
 		}
-		if debug {
-			log.Printf("Received %d bytes from %s\n", n, clientAddr)
+		defer relay.Close()
+		for _, problem := range relay.Check(lotConfig) {
+			log.Printf("Warning: %s", problem)
 		}
-		if n == expectedBlockLength {
-			buf := bytes.NewReader(buffer[:n])
-			curSession.Events++
-			curCircle.Events++
-
-			if config.General.SaveEachNth > 0 && (curSession.Events-1)%config.General.SaveEachNth != 0 {
-				continue
-			}
-
-			cur := lot_config.Datagram{}
-			cur.ParseDatagram(buf, &lotConfig.StreamFormats)
-
-			if cur.ZeroPosition() {
-				if curSessionReported {
-					// Ignore telemetry with zero position after reporting - wait for restart
-					continue
-				}
-			} else {
-				if curSessionReported {
-					curSessionReported = false
-					// Discard previous session data - it was an empty, fake session after race finished until new started
-					curSession = Trip{Type: curSession.Type, Start: time.Now(), Index: curSession.Index}
-				}
-			}
-
-			var distance float64
-
-			if firstEvent == nil {
-				firstEvent = &cur
-			} else {
-				if lotConfig.HasPosition() {
-					distance = cur.DistanceFrom(firstEvent)
-					if distance > curSession.MaxDistance {
-						curSession.MaxDistance = distance
-					}
-
-					if distance > curCircle.MaxDistance {
-						curCircle.MaxDistance = distance
-					}
-				}
-			}
-
-			if lotConfig.HasVelocity() {
-				for i := range cur.Velocity {
-					if curSession.MaxVelocity < cur.Velocity[i] {
-						curSession.MaxVelocity = cur.Velocity[i]
-					}
-					if curCircle.MaxVelocity < cur.Velocity[i] {
-						curCircle.MaxVelocity = cur.Velocity[i]
-					}
-				}
-			}
-
-			if prev != nil {
-				if lotConfig.HasPosition() {
-					curSession.TripDistance += cur.DistanceFrom(prev)
-					curCircle.TripDistance += cur.DistanceFrom(prev)
-				}
-
-				// When we restart race - get timestamp less than before
-				if prev.Timestamp > cur.Timestamp ||
-					//	When race is finished, zero position is constantly sent
-					(lotConfig.HasPosition() && prev.ZeroPosition() && cur.ZeroPosition()) {
-					curSession.Report()
-					curSessionReported = true
-
-					writer.Restart()
-					curSession = Trip{Type: "Race", Start: time.Now(), Index: curSession.Index + 1}
-					curCircle = Trip{Type: "Circle", Start: time.Now(), Index: 1}
-					firstEvent = &cur
-				}
-
-				if lotConfig.HasPosition() {
-					// Let's say that we did a circle if distance from start point is less than some value AND current cicle max distance is bigger then current 50 times
-					if distance < CIRCLE_DISTANCE_TO_START && curCircle.TripDistance > 100 && (curCircle.TripDistance/curCircle.MaxDistance+0.1) > 2 {
-						curCircle.End = time.Now()
-						curCircle.DurationSeconds = int(curCircle.End.Sub(curCircle.Start).Round(time.Second))
-						curCircle.Report()
-
-						curCircle = Trip{Type: curCircle.Type, Start: time.Now(), Index: curCircle.Index + 1}
-					}
-				}
-			}
-
-			writer.Write(&cur, &curSession)
-
-			if debug {
-				log.Printf("%+v", cur)
-			}
-			prev = &cur
-		} else {
-			log.Fatalf("Received unexpected UDP block length %d instead of %d", n, expectedBlockLength)
+		telemetrySource.SubscribeRaw(relay.ForwardRaw)
+		telemetrySource.SubscribeFunc(relay.Forward)
+		log.Printf("Relaying telemetry to %v", config.Relay.Targets)
+		if !config.Relay.Record {
+			return runRelay(ctx, telemetrySource, relay)
 		}
 	}
+
+	packets := telemetrySource.Subscribe(64, lot_source.Block)
+	sourceDone := make(chan error, 1)
+	go func() {
+		sourceDone <- telemetrySource.Run(ctx)
+	}()
+
+	recorder := NewRecorder(config, lotConfig)
+	recorder.OnReport = func() {
+		log.Printf("Telemetry packets: %v", telemetrySource.Stats())
+		logRelayStats(relay)
+	}
+	for packet := range packets.C() {
+		recorder.Handle(packet)
+	}
+
+	// Packets channel is closed when the source stops - by signal or on error
+	status := 0
+	if err := <-sourceDone; err != nil {
+		log.Printf("Error listening: %v", err)
+		status = 1
+	}
+	recorder.Finish(time.Now())
+	return status
+}
+
+// validateLiftoffConfig logs warnings and returns error if the recorder cannot use the config
+func validateLiftoffConfig(lotConfig *lot_config.LiftoffTelemetryConfig) error {
+	warnings, err := lotConfig.Validate(lot_config.Timestamp, lot_config.Position)
+	for _, warning := range warnings {
+		log.Printf("Warning: %s", warning)
+	}
+	return err
 }
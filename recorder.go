@@ -0,0 +1,381 @@
+package main
+
+import (
+	"log"
+	"math"
+	"slices"
+	"time"
+
+	lot_config "github.com/dladlk/liftoff-telemetry/data"
+	lot_records "github.com/dladlk/liftoff-telemetry/records"
+	lot_source "github.com/dladlk/liftoff-telemetry/source"
+)
+
+// Recorder splits packets into races and circles, reports their stats and writes them to files.
+// Times of trips are taken from packets, so replayed recordings get the same durations as live ones.
+type Recorder struct {
+	// OnReport is called after each race is reported, to log stats of packet sources
+	OnReport func()
+
+	config     *Config
+	lotConfig  *lot_config.LiftoffTelemetryConfig
+	debug      bool
+	writer     Writer
+	calculator *lot_config.KinematicsCalculator
+	crashes    *lot_config.CrashDetector
+	tricks     *lot_config.TrickDetector
+
+	// Current and previous datagrams are copied in turn into the same 2 slots to avoid allocations
+	datagrams [2]lot_config.Datagram
+	slot      int
+	prev      *lot_config.Datagram
+
+	segmenter  *lot_config.SessionSegmenter
+	laps       *lot_config.LapTimer // Nil if no track with start/finish gate is found, circles are detected then
+	store      *lot_records.Store   // Nil if personal bests are not kept
+	ghost      *lot_config.Ghost    // Best lap of the track to compare with, nil if there is none
+	trace      *lot_config.TraceRecorder
+	curSession Trip
+	curCircle  Trip
+	circles    []Trip // Reported laps or circles of the session
+	firstEvent lot_config.Datagram
+	lastEvent  float32 // Timestamp of the last datagram of the session
+}
+
+func NewRecorder(config *Config, lotConfig *lot_config.LiftoffTelemetryConfig) *Recorder {
+	r := &Recorder{
+		config:     config,
+		lotConfig:  lotConfig,
+		debug:      config.Log.Debug,
+		calculator: lot_config.NewKinematicsCalculator(lotConfig.StreamFormats, lot_config.DefaultKinematicsSmoothing),
+		segmenter:  lot_config.NewSessionSegmenter(lotConfig.StreamFormats),
+		crashes:    lot_config.NewCrashDetector(lotConfig.StreamFormats),
+		tricks:     lot_config.NewTrickDetector(lotConfig.StreamFormats),
+		trace:      lot_config.NewTraceRecorder(lot_config.DefaultTraceInterval),
+	}
+	if config.Records.Dir != "" {
+		r.store = lot_records.NewStore(config.Records.Dir)
+		r.store.Top = config.Records.Top
+	}
+	r.writer.Start(config, lotConfig)
+	return r
+}
+
+func (r *Recorder) Handle(packet *lot_source.Packet) {
+	now := packet.Received
+	if packet.Config != r.lotConfig {
+		// Telemetry config is reloaded - continue the session into a new file with the new schema
+		r.lotConfig = packet.Config
+		r.calculator = lot_config.NewKinematicsCalculator(r.lotConfig.StreamFormats, lot_config.DefaultKinematicsSmoothing)
+		r.crashes = lot_config.NewCrashDetector(r.lotConfig.StreamFormats)
+		r.tricks = lot_config.NewTrickDetector(r.lotConfig.StreamFormats)
+		r.segmenter.SetFields(r.lotConfig.StreamFormats)
+		r.curSession.Flight.SetFields(r.lotConfig.StreamFormats)
+		r.curCircle.Flight.SetFields(r.lotConfig.StreamFormats)
+		r.writer.Close()
+		r.writer.Start(r.config, r.lotConfig)
+	}
+	lotConfig := r.lotConfig
+	if r.debug {
+		log.Printf("Received %d bytes from %s\n", len(packet.Raw), packet.From)
+	}
+	cur := &r.datagrams[r.slot]
+	packet.Datagram.CopyTo(cur)
+
+	events := r.segmenter.Update(cur)
+	healthEvent := r.segmenter.HealthEvent()
+	if r.debug && healthEvent.Kind != lot_config.HealthOk && healthEvent.Kind != lot_config.HealthFrozen {
+		log.Printf("Stream %v", healthEvent)
+	}
+	for _, event := range events {
+		r.handleSessionEvent(event, cur, now)
+	}
+	if !r.segmenter.InSession() {
+		// Zero position is sent after the race is finished until a new one starts
+		return
+	}
+
+	if healthEvent.Kind == lot_config.HealthReordered {
+		// Late packet is older than already processed ones - it is recorded, but derived values, laps, crashes and tricks skip it
+		if r.countEvent() {
+			r.writer.Write(cur, nil, math.NaN(), &r.curSession)
+		}
+		return
+	}
+
+	r.lastEvent = cur.Timestamp
+
+	// Kinematics are derived once from every packet, even not saved ones, crashes and tricks are detected by them
+	kinematics := r.calculator.Update(cur)
+	if crash, ok := r.crashes.Update(cur, &kinematics); ok {
+		log.Printf("Crash: %v", crash)
+		r.curSession.Crashes = append(r.curSession.Crashes, crash)
+		r.curCircle.Crashes = append(r.curCircle.Crashes, crash)
+	}
+	for _, trick := range r.tricks.Update(cur, &kinematics) {
+		log.Printf("Trick: %v", trick)
+		r.curSession.Tricks = append(r.curSession.Tricks, trick)
+	}
+
+	ghostDelta := math.NaN()
+	if r.laps != nil {
+		// Laps are timed by every packet, even not saved ones
+		started := r.laps.Started()
+		event := r.laps.Update(cur)
+		for _, split := range event.Splits {
+			// The last sector of a completed lap is reported with the lap
+			if lap := event.Lap; lap == nil || split.Lap != lap.Index || split.Sector != len(lap.Sectors) {
+				log.Printf("Split %v", split)
+			}
+		}
+		if lap := event.Lap; lap != nil {
+			r.curCircle.LapTime = lap.Time
+			r.curCircle.Invalid = lap.Invalid
+			r.curCircle.Sectors = lap.Sectors
+			r.curCircle.SectorDeltas = lap.Deltas
+			if r.ghost != nil {
+				r.curCircle.GhostDeltas = slices.Clone(r.ghost.Finish(lap.Time))
+			}
+			r.reportCircle(now)
+			r.curSession.AddLap(lap)
+			trace := r.trace.Finish(cur.Position, lap.Time)
+			if lap.Valid() {
+				if outcome, ok := r.addRecord(lot_records.Lap, lap.Time, trace, now); ok && outcome.PB {
+					r.ghost = lot_config.NewGhost(trace, lot_config.DefaultGhostSegments)
+				}
+			}
+			r.curCircle = r.newTrip(r.curCircle.Type, r.curCircle.Index+1, now)
+			r.startLap()
+		} else if !started && r.laps.Started() {
+			// Time before the first crossing of start/finish gate is not a lap
+			r.curCircle = r.newTrip(r.curCircle.Type, 1, now)
+			r.startLap()
+		}
+		if lapTime, ok := r.laps.LapTime(cur); ok && event.Lap == nil {
+			r.trace.Add(cur.Position, lapTime)
+			ghostDelta = r.updateGhost(cur, lapTime)
+		}
+	}
+
+	if !r.countEvent() {
+		return
+	}
+
+	var distance float64
+	if lotConfig.HasPosition() {
+		distance = cur.DistanceFrom(&r.firstEvent)
+		if distance > r.curSession.MaxDistance {
+			r.curSession.MaxDistance = distance
+		}
+
+		if distance > r.curCircle.MaxDistance {
+			r.curCircle.MaxDistance = distance
+		}
+	}
+
+	r.curSession.AddKinematics(cur, &kinematics)
+	r.curCircle.AddKinematics(cur, &kinematics)
+
+	if r.prev != nil && lotConfig.HasPosition() {
+		r.curSession.TripDistance += cur.DistanceFrom(r.prev)
+		r.curCircle.TripDistance += cur.DistanceFrom(r.prev)
+	}
+
+	if r.laps == nil && r.prev != nil && lotConfig.HasPosition() {
+		// Let's say that we did a circle if distance from start point is less than some value AND current cicle max distance is bigger then current 50 times
+		if distance < CIRCLE_DISTANCE_TO_START && r.curCircle.TripDistance > 100 && (r.curCircle.TripDistance/r.curCircle.MaxDistance+0.1) > 2 {
+			r.reportCircle(now)
+
+			r.curCircle = r.newTrip(r.curCircle.Type, r.curCircle.Index+1, now)
+		}
+	}
+
+	r.writer.Write(cur, &kinematics, ghostDelta, &r.curSession)
+
+	if r.debug {
+		log.Printf("%+v", *cur)
+	}
+	r.prev = cur
+	r.slot ^= 1
+}
+
+// countEvent counts the packet in the session and the circle, false if it is not saved because of sampling
+func (r *Recorder) countEvent() bool {
+	r.curSession.Events++
+	r.curCircle.Events++
+	return r.config.General.SaveEachNth <= 0 || (r.curSession.Events-1)%r.config.General.SaveEachNth == 0
+}
+
+func (r *Recorder) handleSessionEvent(event lot_config.SessionEvent, cur *lot_config.Datagram, now time.Time) {
+	if r.debug {
+		log.Printf("Race %v", event)
+	}
+	switch event.Kind {
+	case lot_config.SessionStarted:
+		r.selectTrack(cur)
+		r.curSession = r.newTrip("Race", event.Index, now)
+		r.curCircle = r.newTrip("Circle", 1, now)
+		if r.laps != nil {
+			r.curCircle.Type = "Lap"
+		}
+		r.circles = nil
+		r.calculator.Reset()
+		r.crashes.Reset()
+		r.tricks.Reset()
+		r.prev = nil
+		cur.CopyTo(&r.firstEvent)
+	case lot_config.SessionEnded:
+		r.addBestSectors()
+		r.curSession.Report(now)
+		if event.Reason == lot_config.EndFinished && r.curSession.Laps > 0 && r.curSession.InvalidLaps == 0 {
+			r.addRecord(lot_records.Race, r.curSession.LapsTime, nil, now)
+		}
+		r.writeSummary()
+		r.logStats()
+		r.writer.Restart()
+	case lot_config.SessionDiscarded:
+		// Drone did not move, e.g. race is restarted before start - recorded file is kept anyway
+		log.Printf("%s #%d is discarded: %v before moving from the start", r.curSession.Type, r.curSession.Index, event.Reason)
+		r.writer.Restart()
+	}
+}
+
+// selectTrack finds the configured track or the one spawning at the start position and starts timing its laps
+func (r *Recorder) selectTrack(start *lot_config.Datagram) {
+	track := lot_config.FindTrack(r.config.Tracks, r.config.General.Track, start)
+	if track == nil {
+		if r.laps != nil {
+			log.Printf("No track is found at start position %v, laps are not timed", start.Position)
+		}
+		r.laps = nil
+		r.ghost = nil
+		return
+	}
+	if r.laps == nil || r.laps.Track() != track {
+		log.Printf("Track %s, laps are timed by start/finish gate", track.Name)
+		r.laps = lot_config.NewLapTimer(track)
+		r.loadGhost(track)
+	}
+	r.laps.Reset()
+}
+
+// addRecord saves result of the current track and logs how it compares to the personal best
+func (r *Recorder) addRecord(kind lot_records.Kind, time float64, trace []lot_config.TracePoint, now time.Time) (lot_records.Outcome, bool) {
+	if r.store == nil || r.laps == nil {
+		return lot_records.Outcome{}, false
+	}
+	track := r.laps.Track().Name
+	result := lot_records.Result{Time: time, Date: now, Session: r.writer.FileName(), Trace: trace}
+	outcome, err := r.store.Add(track, r.config.General.Drone, kind, result)
+	if err != nil {
+		log.Printf("Failed to save personal best: %v", err)
+		return lot_records.Outcome{}, false
+	}
+	log.Printf("%s, %s: %v", track, r.config.General.Drone, outcome)
+	return outcome, true
+}
+
+// startLap starts recording trace of a new lap and comparing it with the ghost
+func (r *Recorder) startLap() {
+	r.trace.Reset()
+	if r.ghost != nil {
+		r.ghost.Reset()
+	}
+}
+
+// updateGhost returns delta to the best lap at the current position, NaN if it is unknown, and logs it at the end of each segment
+func (r *Recorder) updateGhost(cur *lot_config.Datagram, lapTime float64) float64 {
+	if r.ghost == nil {
+		return math.NaN()
+	}
+	delta, segment, ok := r.ghost.Update(cur.Position, lapTime)
+	if !ok {
+		return math.NaN()
+	}
+	if segment > 0 {
+		log.Printf("Ghost lap #%d segment %d/%d: %+.3f s", r.curCircle.Index, segment, lot_config.DefaultGhostSegments, delta)
+	}
+	return delta
+}
+
+// loadGhost takes the best lap of the track from personal bests
+func (r *Recorder) loadGhost(track *lot_config.Track) {
+	r.ghost = nil
+	if r.store == nil {
+		return
+	}
+	records, err := r.store.Load(track.Name, r.config.General.Drone)
+	if err != nil {
+		log.Printf("Failed to read personal bests: %v", err)
+		return
+	}
+	if trace := records.BestTrace(); len(trace) > 1 {
+		r.ghost = lot_config.NewGhost(trace, lot_config.DefaultGhostSegments)
+		log.Printf("Ghost is the best lap %.3f s of %s", r.ghost.Duration(), records.Laps.Best[0].Date.Local().Format("2006-01-02 15:04"))
+	}
+}
+
+func (r *Recorder) newTrip(kind string, index int, now time.Time) Trip {
+	return Trip{Type: kind, Start: now, Index: index, Flight: lot_config.NewFlightStats(r.lotConfig.StreamFormats, r.config.General.SpeedThresholds)}
+}
+
+// reportCircle logs the finished lap or circle and keeps it for the session summary
+func (r *Recorder) reportCircle(now time.Time) {
+	r.curCircle.Report(now)
+	r.circles = append(r.circles, r.curCircle)
+}
+
+// writeSummary writes summary of the reported session next to its recording
+func (r *Recorder) writeSummary() {
+	summary := Summary{
+		Version:        VERSION,
+		Drone:          r.config.General.Drone,
+		FirstTimestamp: r.firstEvent.Timestamp,
+		LastTimestamp:  r.lastEvent,
+		Race:           r.curSession,
+		Laps:           r.circles,
+		Health:         r.segmenter.Health().Stats(),
+	}
+	if r.laps != nil {
+		summary.Track = r.laps.Track().Name
+	}
+	r.writer.WriteSummary(&summary)
+}
+
+// addBestSectors adds best sectors of the track to the race report
+func (r *Recorder) addBestSectors() {
+	if r.laps == nil {
+		return
+	}
+	if best, ok := r.laps.TheoreticalBest(); ok {
+		r.curSession.BestSectors = slices.Clone(r.laps.BestSectors())
+		r.curSession.TheoreticalBest = best
+	}
+}
+
+// Finish reports not yet reported trips at given time and closes the file
+func (r *Recorder) Finish(now time.Time) {
+	for _, event := range r.segmenter.Stop() {
+		switch event.Kind {
+		case lot_config.SessionEnded:
+			if r.laps == nil && r.curCircle.Events > 0 {
+				r.reportCircle(now)
+			}
+			r.addBestSectors()
+			r.curSession.Report(now)
+			r.writeSummary()
+		case lot_config.SessionDiscarded:
+			log.Printf("%s #%d is discarded: %v before moving from the start", r.curSession.Type, r.curSession.Index, event.Reason)
+		}
+	}
+	r.writer.Close()
+	r.logStats()
+}
+
+func (r *Recorder) logStats() {
+	log.Printf("Stream health: %v", r.segmenter.Health().Stats())
+	r.segmenter.Health().ResetStats()
+	if r.OnReport != nil {
+		r.OnReport()
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	lot_records "github.com/dladlk/liftoff-telemetry/records"
+)
+
+// RECORDS_TREND is the number of recent results compared with the same number of results before them
+const RECORDS_TREND = 5
+
+// runRecords prints personal bests and trends of all or chosen track and drone, returns exit status
+func runRecords(args []string) int {
+	cli := newCliFlags("records")
+	cli.set.Usage = func() {
+		fmt.Fprintf(cli.set.Output(), "Usage: %s records [-track name] [-drone name]\n", os.Args[0])
+		cli.set.PrintDefaults()
+	}
+	config, err := cli.loadConfig(args)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	store := lot_records.NewStore(config.Records.Dir)
+	list, err := store.List()
+	if err != nil {
+		log.Fatalf("Failed to read personal bests from %s: %v", config.Records.Dir, err)
+	}
+
+	printed := 0
+	for _, records := range list {
+		if cli.track != "" && records.Track != cli.track || cli.drone != "" && records.Drone != cli.drone {
+			continue
+		}
+		fmt.Printf("%s, %s\n", records.Track, records.Drone)
+		printResults(lot_records.Lap, &records.Laps)
+		printResults(lot_records.Race, &records.Races)
+		printed++
+	}
+	if printed == 0 {
+		fmt.Printf("No personal bests in %s yet\n", config.Records.Dir)
+	}
+	return 0
+}
+
+func printResults(kind lot_records.Kind, results *lot_records.Results) {
+	if len(results.Best) == 0 {
+		return
+	}
+	fmt.Printf("  Best %ss:\n", kind)
+	for i, result := range results.Best {
+		fmt.Printf("  %3d. %8.3f s  %s  %s\n", i+1, result.Time, result.Date.Local().Format("2006-01-02 15:04"), result.Session)
+	}
+	if last, previous, ok := results.Trend(RECORDS_TREND); ok {
+		fmt.Printf("  Trend: last %d %ss average %.3f s, %+.3f to previous %d\n", RECORDS_TREND, kind, last, last-previous, RECORDS_TREND)
+	}
+}
@@ -0,0 +1,79 @@
+package lot_records
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	lockTimeout = 5 * time.Second
+	// Lock file of a crashed recorder is removed after this time
+	lockStale = 30 * time.Second
+	lockRetry = 10 * time.Millisecond
+)
+
+// lock creates a lock file next to path, which is removed by the returned function
+func lock(path string) (func(), error) {
+	lockPath := path + ".lock"
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		file, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			fmt.Fprintf(file, "%d", os.Getpid())
+			file.Close()
+			return func() { os.Remove(lockPath) }, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return nil, err
+		}
+		if info, err := os.Stat(lockPath); err == nil && time.Since(info.ModTime()) > lockStale {
+			removeStale(lockPath)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("Failed to lock %s, remove %s if no recorder is running", path, lockPath)
+		}
+		time.Sleep(lockRetry)
+	}
+}
+
+// removeStale removes the lock file of a crashed recorder. Other recorders may see the same stale lock, so it is moved away first,
+// which only one of them succeeds in, and a fresh lock taken by another recorder after removing the stale one is put back.
+func removeStale(lockPath string) {
+	claimed := fmt.Sprintf("%s.%d.stale", lockPath, os.Getpid())
+	if err := os.Rename(lockPath, claimed); err != nil {
+		return
+	}
+	if info, err := os.Stat(claimed); err == nil && time.Since(info.ModTime()) <= lockStale {
+		// Link does not replace a lock taken meanwhile, unlike rename
+		os.Link(claimed, lockPath)
+	}
+	os.Remove(claimed)
+}
+
+// writeAtomic writes data to a temporary file and renames it to path, so path has either old or new content after a crash
+func writeAtomic(path string, data []byte) error {
+	file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmp := file.Name()
+	_, err = file.Write(data)
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return err
+}
@@ -0,0 +1,233 @@
+package lot_records
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	lot_config "github.com/dladlk/liftoff-telemetry/data"
+)
+
+// Kind of result: time of a single lap or of the whole race
+type Kind string
+
+const (
+	Lap  Kind = "lap"
+	Race Kind = "race"
+)
+
+const (
+	DefaultTop    = 10
+	DefaultRecent = 50
+	DefaultDrone  = "default"
+)
+
+type Result struct {
+	Time    float64   `json:"time"` // Seconds
+	Date    time.Time `json:"date"`
+	Session string    `json:"session,omitempty"` // File the session is recorded to
+	// Positions during the lap, kept only for the best lap as a ghost to compare with
+	Trace []lot_config.TracePoint `json:"trace,omitempty"`
+}
+
+// Results keeps the best results sorted by time and the recent ones in order they were added
+type Results struct {
+	Best   []Result `json:"best"`
+	Recent []Result `json:"recent"`
+}
+
+// Records are results of a drone on a track
+type Records struct {
+	Track string  `json:"track"`
+	Drone string  `json:"drone"`
+	Laps  Results `json:"laps"`
+	Races Results `json:"races"`
+}
+
+// BestTrace returns trace of the best lap, nil if it is not recorded
+func (r *Records) BestTrace() []lot_config.TracePoint {
+	if len(r.Laps.Best) == 0 {
+		return nil
+	}
+	return r.Laps.Best[0].Trace
+}
+
+func (r *Records) Results(kind Kind) *Results {
+	if kind == Race {
+		return &r.Races
+	}
+	return &r.Laps
+}
+
+// Outcome tells how a new result compares to the previous best
+type Outcome struct {
+	Kind     Kind
+	Time     float64
+	PB       bool    // New personal best
+	Previous float64 // Previous best time, 0 if there was none
+	Rank     int     // Place in the best results starting with 1, 0 if it is not in top
+}
+
+// Delta returns difference to the previous best, negative if the result is faster
+func (o Outcome) Delta() float64 {
+	if o.Previous == 0 {
+		return 0
+	}
+	return o.Time - o.Previous
+}
+
+func (o Outcome) String() string {
+	switch {
+	case o.PB && o.Previous > 0:
+		return fmt.Sprintf("new PB %s %.3f s (%+.3f)", o.Kind, o.Time, o.Delta())
+	case o.PB:
+		return fmt.Sprintf("first PB %s %.3f s", o.Kind, o.Time)
+	case o.Rank > 0:
+		return fmt.Sprintf("%s %.3f s is %+.3f to PB, #%d in top", o.Kind, o.Time, o.Delta(), o.Rank)
+	}
+	return fmt.Sprintf("%s %.3f s is %+.3f to PB", o.Kind, o.Time, o.Delta())
+}
+
+// Store keeps records in JSON files, one per track and drone.
+// Files are replaced atomically under a lock file, so concurrent recorders and crashes do not lose or corrupt them.
+type Store struct {
+	Dir    string
+	Top    int // Best results kept
+	Recent int // Recent results kept for trends
+}
+
+func NewStore(dir string) *Store {
+	return &Store{Dir: dir, Top: DefaultTop, Recent: DefaultRecent}
+}
+
+func (s *Store) path(track, drone string) string {
+	return filepath.Join(s.Dir, fileName(track)+"__"+fileName(drone)+".json")
+}
+
+// fileName replaces characters which are not safe in file names on all platforms
+func fileName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// Load returns records of the drone on the track, empty ones if there are none yet
+func (s *Store) Load(track, drone string) (*Records, error) {
+	return readRecords(s.path(track, drone), track, drone)
+}
+
+func readRecords(path, track, drone string) (*Records, error) {
+	records := &Records{Track: track, Drone: drone}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return records, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, records); err != nil {
+		return nil, fmt.Errorf("Failed to parse records %s: %w", path, err)
+	}
+	return records, nil
+}
+
+// Add saves a new result and returns how it compares to the previous best
+func (s *Store) Add(track, drone string, kind Kind, result Result) (Outcome, error) {
+	if err := os.MkdirAll(s.Dir, 0755); err != nil {
+		return Outcome{}, err
+	}
+	path := s.path(track, drone)
+	unlock, err := lock(path)
+	if err != nil {
+		return Outcome{}, err
+	}
+	defer unlock()
+
+	// Read under the lock, another recorder may have added results since
+	records, err := readRecords(path, track, drone)
+	if err != nil {
+		return Outcome{}, err
+	}
+	results := records.Results(kind)
+	outcome := Outcome{Kind: kind, Time: result.Time}
+	if len(results.Best) > 0 {
+		outcome.Previous = results.Best[0].Time
+	}
+	outcome.PB = outcome.Previous == 0 || result.Time < outcome.Previous
+
+	rank, _ := slices.BinarySearchFunc(results.Best, result.Time, func(r Result, t float64) int {
+		if r.Time <= t {
+			return -1
+		}
+		return 1
+	})
+	if rank < s.Top {
+		results.Best = slices.Insert(results.Best, rank, result)
+		outcome.Rank = rank + 1
+	}
+	if len(results.Best) > s.Top {
+		results.Best = results.Best[:s.Top]
+	}
+	for i := 1; i < len(results.Best); i++ {
+		results.Best[i].Trace = nil
+	}
+	result.Trace = nil
+	results.Recent = append(results.Recent, result)
+	if len(results.Recent) > s.Recent {
+		results.Recent = results.Recent[len(results.Recent)-s.Recent:]
+	}
+
+	b, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return Outcome{}, err
+	}
+	return outcome, writeAtomic(path, b)
+}
+
+// List returns records of all tracks and drones, sorted by track and drone
+func (s *Store) List() ([]*Records, error) {
+	paths, err := filepath.Glob(filepath.Join(s.Dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	var res []*Records
+	for _, path := range paths {
+		records, err := readRecords(path, "", "")
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, records)
+	}
+	slices.SortFunc(res, func(a, b *Records) int {
+		if c := strings.Compare(a.Track, b.Track); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Drone, b.Drone)
+	})
+	return res, nil
+}
+
+// Trend returns average times of the last n recent results and of n results before them, false if there are not enough results
+func (r *Results) Trend(n int) (last float64, previous float64, ok bool) {
+	if n <= 0 || len(r.Recent) < 2*n {
+		return 0, 0, false
+	}
+	average := func(results []Result) float64 {
+		var sum float64
+		for _, result := range results {
+			sum += result.Time
+		}
+		return sum / float64(len(results))
+	}
+	recent := r.Recent[len(r.Recent)-2*n:]
+	return average(recent[n:]), average(recent[:n]), true
+}
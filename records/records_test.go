@@ -0,0 +1,169 @@
+package lot_records_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	lot_config "github.com/dladlk/liftoff-telemetry/data"
+	lot_records "github.com/dladlk/liftoff-telemetry/records"
+)
+
+func TestStore_Add(t *testing.T) {
+	store := lot_records.NewStore(t.TempDir())
+	store.Top = 3
+
+	tests := []struct {
+		time     float64
+		wantPB   bool
+		wantPrev float64
+		wantRank int
+	}{
+		{time: 30, wantPB: true, wantRank: 1},
+		{time: 31, wantPrev: 30, wantRank: 2},
+		{time: 29.5, wantPB: true, wantPrev: 30, wantRank: 1},
+		{time: 33, wantPrev: 29.5}, // Top 3 is full with faster laps
+		{time: 35, wantPrev: 29.5},
+		{time: 30.5, wantPrev: 29.5, wantRank: 3},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.time), func(t *testing.T) {
+			trace := []lot_config.TracePoint{{Time: tt.time}}
+			got, err := store.Add("Drone Park", "Chameleon", lot_records.Lap, lot_records.Result{Time: tt.time, Date: time.Now(), Trace: trace})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.PB != tt.wantPB || got.Previous != tt.wantPrev || got.Rank != tt.wantRank {
+				t.Errorf("Add() = %+v, want PB %v, previous %v, rank %d", got, tt.wantPB, tt.wantPrev, tt.wantRank)
+			}
+		})
+	}
+
+	records, err := store.Load("Drone Park", "Chameleon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var best []float64
+	for _, r := range records.Laps.Best {
+		best = append(best, r.Time)
+	}
+	if fmt.Sprint(best) != "[29.5 30 30.5]" {
+		t.Errorf("Best laps = %v, want [29.5 30 30.5]", best)
+	}
+	if len(records.Laps.Recent) != len(tests) || len(records.Races.Best) != 0 {
+		t.Errorf("Records = %+v, want %d recent laps and no races", records, len(tests))
+	}
+	if trace := records.BestTrace(); len(trace) != 1 || trace[0].Time != 29.5 {
+		t.Errorf("BestTrace() = %v, want trace of 29.5 lap", trace)
+	}
+	for _, r := range slices.Concat(records.Laps.Best[1:], records.Laps.Recent) {
+		if r.Trace != nil {
+			t.Errorf("Trace of not the best lap %v is kept", r.Time)
+		}
+	}
+	if last, previous, ok := records.Laps.Trend(3); !ok || last != (33+35+30.5)/3 || previous != (30+31+29.5)/3 {
+		t.Errorf("Trend(3) = %v, %v, %v", last, previous, ok)
+	}
+}
+
+func TestStore_ConcurrentAdd(t *testing.T) {
+	dir := t.TempDir()
+	const writers, results = 4, 10
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// Each writer has its own store like a separate recorder process
+			store := lot_records.NewStore(dir)
+			for i := 0; i < results; i++ {
+				if _, err := store.Add("Track", lot_records.DefaultDrone, lot_records.Lap, lot_records.Result{Time: float64(w*results + i + 1)}); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	records, err := lot_records.NewStore(dir).Load("Track", lot_records.DefaultDrone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records.Laps.Recent) != writers*results {
+		t.Errorf("Recent laps = %d, want %d - results are lost", len(records.Laps.Recent), writers*results)
+	}
+	if records.Laps.Best[0].Time != 1 {
+		t.Errorf("Best lap = %v, want 1", records.Laps.Best[0].Time)
+	}
+	leftovers, _ := filepath.Glob(filepath.Join(dir, "*.tmp"))
+	locks, _ := filepath.Glob(filepath.Join(dir, "*.lock"))
+	if len(leftovers) > 0 || len(locks) > 0 {
+		t.Errorf("Left files %v %v", leftovers, locks)
+	}
+}
+
+func TestStore_StaleLock(t *testing.T) {
+	dir := t.TempDir()
+	store := lot_records.NewStore(dir)
+	if _, err := store.Add("Track", "Drone", lot_records.Race, lot_records.Result{Time: 60}); err != nil {
+		t.Fatal(err)
+	}
+	// Recorder crashed while holding the lock
+	lockPath := filepath.Join(dir, "Track__Drone.json.lock")
+	if err := os.WriteFile(lockPath, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-time.Hour)
+	os.Chtimes(lockPath, old, old)
+
+	if _, err := store.Add("Track", "Drone", lot_records.Race, lot_records.Result{Time: 59}); err != nil {
+		t.Fatalf("Add() with stale lock failed: %v", err)
+	}
+	list, err := store.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].Track != "Track" || len(list[0].Races.Best) != 2 {
+		t.Errorf("List() = %+v", list)
+	}
+}
+
+func TestStore_ConcurrentStaleLock(t *testing.T) {
+	dir := t.TempDir()
+	lockPath := filepath.Join(dir, "Track__Drone.json.lock")
+	if err := os.WriteFile(lockPath, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-time.Hour)
+	os.Chtimes(lockPath, old, old)
+
+	// Recorders started together all see the stale lock, but only one of them may remove it
+	const writers = 8
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := lot_records.NewStore(dir).Add("Track", "Drone", lot_records.Race, lot_records.Result{Time: float64(w + 1)}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	records, err := lot_records.NewStore(dir).Load("Track", "Drone")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records.Races.Recent) != writers {
+		t.Errorf("Recent races = %d, want %d - results are lost", len(records.Races.Recent), writers)
+	}
+	left, _ := filepath.Glob(filepath.Join(dir, "*.lock*"))
+	if len(left) > 0 {
+		t.Errorf("Left files %v", left)
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	lot_source "github.com/dladlk/liftoff-telemetry/source"
+)
+
+const RELAY_STATS_INTERVAL = time.Minute
+
+// runRelay only forwards telemetry without recording until the source stops, returns exit status
+func runRelay(ctx context.Context, telemetrySource *lot_source.Source, relay *lot_source.Relay) int {
+	sourceDone := make(chan error, 1)
+	go func() {
+		sourceDone <- telemetrySource.Run(ctx)
+	}()
+
+	ticker := time.NewTicker(RELAY_STATS_INTERVAL)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			logRelayStats(relay)
+		case err := <-sourceDone:
+			logRelayStats(relay)
+			log.Printf("Telemetry packets: %v", telemetrySource.Stats())
+			if err != nil {
+				log.Printf("Error listening: %v", err)
+				return 1
+			}
+			return 0
+		}
+	}
+}
+
+func logRelayStats(relay *lot_source.Relay) {
+	if relay == nil {
+		return
+	}
+	for _, stats := range relay.Stats() {
+		log.Printf("Relay %v", stats)
+	}
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"slices"
+	"syscall"
+	"time"
+
+	lot_config "github.com/dladlk/liftoff-telemetry/data"
+	lot_source "github.com/dladlk/liftoff-telemetry/source"
+)
+
+// replayClock turns recorded timestamps into times, pauses and restarts of the race do not move it back
+type replayClock struct {
+	now  time.Time
+	last float32
+	init bool
+}
+
+func (c *replayClock) advance(ts float32) time.Time {
+	if c.init && ts > c.last {
+		c.now = c.now.Add(time.Duration(float64(ts-c.last) * float64(time.Second)))
+	}
+	c.init = true
+	c.last = ts
+	return c.now
+}
+
+// runReplay reads binary recordings and either sends them to an endpoint in real time
+// or passes them through the recorder as fast as possible, returns exit status
+func runReplay(args []string) int {
+	cli := newCliFlags("replay")
+	send := cli.set.String("send", "", "Send recordings as Liftoff UDP packets to this endpoint in real time instead of recording them again")
+	speed := cli.set.Float64("speed", 1, "Speed factor of real time sending")
+	cli.set.Usage = func() {
+		fmt.Fprintf(cli.set.Output(), "Usage: %s replay [flags] recording.bin...\n", os.Args[0])
+		cli.set.PrintDefaults()
+	}
+	config, err := cli.loadConfig(args)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	files := cli.set.Args()
+	if len(files) == 0 {
+		cli.set.Usage()
+		return 2
+	}
+	if *speed <= 0 {
+		log.Fatalf("Speed factor must be positive, got %v", *speed)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	for _, file := range files {
+		if *send != "" {
+			err = sendRecording(ctx, file, *send, *speed)
+		} else {
+			err = replayRecording(ctx, file, config)
+		}
+		if err != nil {
+			log.Printf("Failed to replay %s: %v", file, err)
+			return 1
+		}
+		if ctx.Err() != nil {
+			log.Printf("Replay is interrupted")
+			return 1
+		}
+	}
+	return 0
+}
+
+// openRecording opens binary recording and logs its stream formats
+func openRecording(path string) (*os.File, *lot_config.RecordingReader, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	reader, err := lot_config.NewRecordingReader(file)
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	log.Printf("Replay %s with stream formats %v", path, reader.StreamFormatNames())
+	return file, reader, nil
+}
+
+// nextBlock returns the next block of recording, false at the end. Truncated tail of interrupted recording is skipped.
+func nextBlock(reader *lot_config.RecordingReader, blocks int) ([]byte, bool, error) {
+	block, err := reader.Next()
+	if err == nil {
+		return block, true, nil
+	}
+	if errors.Is(err, lot_config.ErrTruncated) {
+		log.Printf("Skip truncated tail after block %d: %v", blocks, err)
+		return nil, false, nil
+	}
+	if err == io.EOF {
+		return nil, false, nil
+	}
+	return nil, false, err
+}
+
+// replayRecording passes recording through the recorder, so races are split, reported and written again
+func replayRecording(ctx context.Context, path string, config *Config) error {
+	file, reader, err := openRecording(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	lotConfig := lot_config.NewLiftoffTelemetryConfig("", reader.StreamFormatNames())
+	decoder := lot_config.NewDecoder(lotConfig.StreamFormats)
+	recorder := NewRecorder(config, lotConfig)
+	packet := lot_source.Packet{Config: lotConfig}
+	clock := replayClock{now: time.Now()}
+	malformed := 0
+
+	for ctx.Err() == nil {
+		block, ok, err := nextBlock(reader, int(packet.Index))
+		if err != nil {
+			recorder.Finish(clock.now)
+			return err
+		}
+		if !ok {
+			break
+		}
+		if err := decoder.Decode(block, &packet.Datagram); err != nil {
+			malformed++
+			continue
+		}
+		packet.Index++
+		packet.Raw = block
+		packet.Received = clock.advance(packet.Datagram.Timestamp)
+		recorder.Handle(&packet)
+	}
+	recorder.Finish(clock.now)
+	log.Printf("Replayed %d packets, skipped %d malformed", packet.Index, malformed)
+	return nil
+}
+
+// sendRecording sends recorded packets unchanged to endpoint, keeping recorded intervals divided by speed factor
+func sendRecording(ctx context.Context, path string, endpoint string, speed float64) error {
+	file, reader, err := openRecording(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if !slices.Contains(reader.Fields(), lot_config.Timestamp) {
+		return errors.New("Recording without Timestamp cannot be sent in real time")
+	}
+
+	address, err := net.ResolveUDPAddr("udp", endpoint)
+	if err != nil {
+		return err
+	}
+	conn, err := net.DialUDP("udp", nil, address)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	decoder := lot_config.NewDecoder(reader.Fields())
+	var datagram lot_config.Datagram
+	start := time.Now()
+	clock := replayClock{now: start}
+	sent := 0
+
+	for {
+		block, ok, err := nextBlock(reader, sent)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			break
+		}
+		if err := decoder.Decode(block, &datagram); err != nil {
+			continue
+		}
+		at := start.Add(time.Duration(float64(clock.advance(datagram.Timestamp).Sub(start)) / speed))
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(time.Until(at)):
+		}
+		if _, err := conn.Write(block); err != nil {
+			return err
+		}
+		sent++
+	}
+	log.Printf("Sent %d packets to %s in %v", sent, endpoint, time.Since(start).Round(time.Millisecond))
+	return nil
+}
@@ -0,0 +1,149 @@
+package lot_source
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"sync/atomic"
+
+	lot_config "github.com/dladlk/liftoff-telemetry/data"
+)
+
+// RelayTarget is a downstream UDP address to forward Liftoff packets to
+type RelayTarget struct {
+	Address string   `toml:"address"`
+	Fields  []string `toml:"fields"`  // Re-encode packets with these stream formats only, raw packets are forwarded if empty
+	EachNth int      `toml:"eachNth"` // Forward only each Nth packet, all if 0 or 1
+}
+
+func (t RelayTarget) String() string {
+	res := t.Address
+	if len(t.Fields) > 0 {
+		res += fmt.Sprintf(" fields %v", t.Fields)
+	}
+	if t.EachNth > 1 {
+		res += fmt.Sprintf(" each %d", t.EachNth)
+	}
+	return res
+}
+
+// RelayStats are counters of a single target
+type RelayStats struct {
+	Target  RelayTarget
+	Sent    uint64
+	Skipped uint64 // Not forwarded because of downsampling
+	Errors  uint64
+}
+
+func (s RelayStats) String() string {
+	return fmt.Sprintf("%s: sent %d, skipped %d, errors %d", s.Target.Address, s.Sent, s.Skipped, s.Errors)
+}
+
+type relayTarget struct {
+	target  RelayTarget
+	fields  []lot_config.StreamDataType
+	conn    *net.UDPConn
+	buf     []byte
+	count   uint64
+	sent    atomic.Uint64
+	skipped atomic.Uint64
+	errors  atomic.Uint64
+}
+
+// Relay forwards packets to several downstream targets, so more tools can consume one Liftoff stream
+type Relay struct {
+	// Logf reports the first send error of each target, log.Printf by default
+	Logf    func(format string, v ...any)
+	targets []*relayTarget
+}
+
+func NewRelay(targets []RelayTarget) (*Relay, error) {
+	r := &Relay{Logf: log.Printf}
+	for _, target := range targets {
+		address, err := net.ResolveUDPAddr("udp", target.Address)
+		if err != nil {
+			r.Close()
+			return nil, fmt.Errorf("Error resolving relay target %s: %w", target.Address, err)
+		}
+		conn, err := net.DialUDP("udp", nil, address)
+		if err != nil {
+			r.Close()
+			return nil, fmt.Errorf("Error connecting to relay target %s: %w", target.Address, err)
+		}
+		var fields []lot_config.StreamDataType
+		if len(target.Fields) > 0 {
+			fields = lot_config.ParseStreamDataTypeFormats(target.Fields)
+		}
+		r.targets = append(r.targets, &relayTarget{target: target, fields: fields, conn: conn})
+	}
+	return r, nil
+}
+
+// Check returns problems of targets which filter fields not sent by Liftoff with the given config
+func (r *Relay) Check(lotConfig *lot_config.LiftoffTelemetryConfig) []string {
+	var problems []string
+	for _, t := range r.targets {
+		for i, field := range t.fields {
+			if field == lot_config.Unknown {
+				problems = append(problems, fmt.Sprintf("relay target %s: unknown stream format '%s'", t.target.Address, t.target.Fields[i]))
+			} else if !lotConfig.HasStreamDataType(field) {
+				problems = append(problems, fmt.Sprintf("relay target %s: stream format '%s' is not sent by Liftoff, zeros are forwarded", t.target.Address, field))
+			}
+		}
+	}
+	return problems
+}
+
+// ForwardRaw sends the packet unchanged to targets without fields, it is meant to be passed to Source.SubscribeRaw,
+// so packets are forwarded even if the current config can not decode them
+func (r *Relay) ForwardRaw(raw []byte) {
+	for _, t := range r.targets {
+		if t.fields == nil && !t.skip() {
+			r.send(t, raw)
+		}
+	}
+}
+
+// Forward sends the decoded packet re-encoded to targets with fields, it is meant to be passed to Source.SubscribeFunc
+func (r *Relay) Forward(p *Packet) {
+	for _, t := range r.targets {
+		if t.fields != nil && !t.skip() {
+			t.buf = p.Datagram.AppendEncoded(t.buf[:0], t.fields)
+			r.send(t, t.buf)
+		}
+	}
+}
+
+// skip counts the packet and tells if it is not forwarded because of downsampling
+func (t *relayTarget) skip() bool {
+	t.count++
+	if t.target.EachNth > 1 && (t.count-1)%uint64(t.target.EachNth) != 0 {
+		t.skipped.Add(1)
+		return true
+	}
+	return false
+}
+
+func (r *Relay) send(t *relayTarget, data []byte) {
+	if _, err := t.conn.Write(data); err != nil {
+		if t.errors.Add(1) == 1 {
+			r.Logf("Failed to relay to %s, further errors are only counted: %v", t.target.Address, err)
+		}
+		return
+	}
+	t.sent.Add(1)
+}
+
+func (r *Relay) Stats() []RelayStats {
+	res := make([]RelayStats, len(r.targets))
+	for i, t := range r.targets {
+		res[i] = RelayStats{Target: t.target, Sent: t.sent.Load(), Skipped: t.skipped.Load(), Errors: t.errors.Load()}
+	}
+	return res
+}
+
+func (r *Relay) Close() {
+	for _, t := range r.targets {
+		t.conn.Close()
+	}
+}
@@ -0,0 +1,98 @@
+package lot_source_test
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+
+	lot_config "github.com/dladlk/liftoff-telemetry/data"
+	lot_source "github.com/dladlk/liftoff-telemetry/source"
+)
+
+func listenTarget(t *testing.T) *net.UDPConn {
+	t.Helper()
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func receive(t *testing.T, conn *net.UDPConn) []byte {
+	t.Helper()
+	buf := make([]byte, 1024)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatalf("Nothing relayed: %v", err)
+	}
+	return buf[:n]
+}
+
+func TestRelay_Forward(t *testing.T) {
+	streamFormats := lot_config.ParseStreamDataTypeFormats(fields)
+	filtered := []string{"Timestamp", "Position"}
+	raw, downsampled, filter := listenTarget(t), listenTarget(t), listenTarget(t)
+
+	relay, err := lot_source.NewRelay([]lot_source.RelayTarget{
+		{Address: raw.LocalAddr().String()},
+		{Address: downsampled.LocalAddr().String(), EachNth: 2},
+		{Address: filter.LocalAddr().String(), Fields: filtered},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer relay.Close()
+
+	var sent [][]byte
+	for i := 0; i < 3; i++ {
+		packet := &lot_source.Packet{Datagram: lot_config.Datagram{Timestamp: float32(i), Position: [3]float32{1, 2, 3}, MotorRPM: []float32{1, 2, 3, 4}}}
+		packet.Raw = packet.Datagram.Encode(streamFormats)
+		sent = append(sent, packet.Raw)
+		relay.ForwardRaw(packet.Raw)
+		relay.Forward(packet)
+	}
+
+	for i := range sent {
+		if got := receive(t, raw); !bytes.Equal(got, sent[i]) {
+			t.Errorf("Raw target got %v, want %v", got, sent[i])
+		}
+	}
+	for _, i := range []int{0, 2} {
+		if got := receive(t, downsampled); !bytes.Equal(got, sent[i]) {
+			t.Errorf("Downsampled target got %v, want packet %d %v", got, i, sent[i])
+		}
+	}
+	decoder := lot_config.NewDecoder(lot_config.ParseStreamDataTypeFormats(filtered))
+	for i := range sent {
+		var d lot_config.Datagram
+		if err := decoder.Decode(receive(t, filter), &d); err != nil {
+			t.Fatalf("Filtered target got undecodable packet: %v", err)
+		}
+		if d.Timestamp != float32(i) || d.Position != [3]float32{1, 2, 3} {
+			t.Errorf("Filtered target got %+v", d)
+		}
+	}
+
+	stats := relay.Stats()
+	want := []struct{ sent, skipped uint64 }{{3, 0}, {2, 1}, {3, 0}}
+	for i, w := range want {
+		if stats[i].Sent != w.sent || stats[i].Skipped != w.skipped || stats[i].Errors != 0 {
+			t.Errorf("Stats of target %d = %v, want sent %d, skipped %d", i, stats[i], w.sent, w.skipped)
+		}
+	}
+}
+
+func TestRelay_Check(t *testing.T) {
+	relay, err := lot_source.NewRelay([]lot_source.RelayTarget{{Address: "127.0.0.1:9", Fields: []string{"Timestamp", "Gyro", "Speed"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer relay.Close()
+	problems := relay.Check(lot_config.NewLiftoffTelemetryConfig("127.0.0.1:9001", fields))
+	if len(problems) != 2 {
+		t.Errorf("Check() = %q, want problems for Gyro and Speed", problems)
+	}
+}
@@ -0,0 +1,255 @@
+package lot_source
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	lot_config "github.com/dladlk/liftoff-telemetry/data"
+)
+
+// Stats are counters of the source since it was created
+type Stats struct {
+	Received  uint64 // UDP packets read, including malformed
+	Malformed uint64 // Packets which failed to decode
+	Dropped   uint64 // Packets lost by subscribers because of their policy
+}
+
+func (s Stats) String() string {
+	return fmt.Sprintf("received %d, malformed %d, dropped %d", s.Received, s.Malformed, s.Dropped)
+}
+
+// Source reads Liftoff telemetry from UDP endpoint, decodes and delivers it to subscribers.
+// Changes of TelemetryConfiguration.json are picked up while running.
+type Source struct {
+	// Logf reports malformed packets, reloads and read errors, log.Printf by default
+	Logf func(format string, v ...any)
+	// WatchInterval is how often config file is checked for changes, 0 disables watching
+	WatchInterval time.Duration
+	// Validate checks changed config before it is applied, changes failing it are ignored
+	Validate func(*lot_config.LiftoffTelemetryConfig) error
+
+	mu            sync.Mutex
+	lotConfig     *lot_config.LiftoffTelemetryConfig
+	conn          *net.UDPConn
+	subscriptions []*Subscription
+	callbacks     []func(*Packet)
+	rawCallbacks  []func([]byte)
+
+	received  atomic.Uint64
+	malformed atomic.Uint64
+}
+
+func New(lotConfig *lot_config.LiftoffTelemetryConfig) *Source {
+	return &Source{Logf: log.Printf, WatchInterval: lot_config.DefaultWatchInterval, lotConfig: lotConfig}
+}
+
+// Config returns current Liftoff telemetry config, which may change on reload
+func (s *Source) Config() *lot_config.LiftoffTelemetryConfig {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lotConfig
+}
+
+// Subscribe returns a subscription with channel of given size. Must be called before Run.
+func (s *Source) Subscribe(size int, policy Policy) *Subscription {
+	sub := newSubscription(size, policy)
+	s.mu.Lock()
+	s.subscriptions = append(s.subscriptions, sub)
+	s.mu.Unlock()
+	return sub
+}
+
+// SubscribeFunc registers a callback called in the receive loop for each packet.
+// The packet is owned by the source and valid only during the call. Must be called before Run.
+func (s *Source) SubscribeFunc(fn func(*Packet)) {
+	s.mu.Lock()
+	s.callbacks = append(s.callbacks, fn)
+	s.mu.Unlock()
+}
+
+// SubscribeRaw registers a callback called in the receive loop for each packet before it is decoded,
+// so it gets even packets the current config can not decode. Bytes are valid only during the call. Must be called before Run.
+func (s *Source) SubscribeRaw(fn func(raw []byte)) {
+	s.mu.Lock()
+	s.rawCallbacks = append(s.rawCallbacks, fn)
+	s.mu.Unlock()
+}
+
+func (s *Source) Stats() Stats {
+	stats := Stats{Received: s.received.Load(), Malformed: s.malformed.Load()}
+	s.mu.Lock()
+	for _, sub := range s.subscriptions {
+		stats.Dropped += sub.Dropped()
+	}
+	s.mu.Unlock()
+	return stats
+}
+
+// LocalAddr returns the address the source is listening on, nil if it is not running
+func (s *Source) LocalAddr() net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		return nil
+	}
+	return s.conn.LocalAddr()
+}
+
+func listenUDP(endpoint string) (*net.UDPConn, error) {
+	address, err := net.ResolveUDPAddr("udp", endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("Error resolving UDP address %s: %w", endpoint, err)
+	}
+	return net.ListenUDP("udp", address)
+}
+
+// Run binds to the configured endpoint and reads packets until the context is cancelled.
+// Subscription channels are closed when it returns. Returns nil if stopped by the context.
+func (s *Source) Run(ctx context.Context) error {
+	s.mu.Lock()
+	lotConfig := s.lotConfig
+	subscriptions := s.subscriptions
+	callbacks := s.callbacks
+	rawCallbacks := s.rawCallbacks
+	s.mu.Unlock()
+	defer func() {
+		for _, sub := range subscriptions {
+			sub.close()
+		}
+	}()
+
+	conn, err := listenUDP(lotConfig.Endpoint)
+	if err != nil {
+		return err
+	}
+	s.setConn(conn)
+	defer func() { s.setConn(nil) }()
+	s.Logf("Liftoff Telemetry UDP server listening on %s\n", conn.LocalAddr().String())
+
+	// Closing the connection interrupts blocked read
+	stop := context.AfterFunc(ctx, func() { s.setConn(nil) })
+	defer stop()
+
+	var watcher *lot_config.ConfigWatcher
+	if s.WatchInterval > 0 {
+		watcher = lot_config.NewConfigWatcher(lotConfig, s.WatchInterval)
+	}
+
+	decoder := lot_config.NewDecoder(lotConfig.StreamFormats)
+	buffer := make([]byte, 1024)
+	packet := Packet{Config: lotConfig}
+
+	for {
+		if ctx.Err() != nil {
+			return nil
+		}
+		if newLotConfig := s.poll(watcher, lotConfig); newLotConfig != nil {
+			if conn, err = s.rebind(conn, newLotConfig, lotConfig); err != nil {
+				return err
+			}
+			lotConfig = newLotConfig
+			decoder = lot_config.NewDecoder(lotConfig.StreamFormats)
+			packet.Config = lotConfig
+		}
+
+		if watcher != nil {
+			// Wake up periodically to poll the config even if Liftoff sends nothing
+			conn.SetReadDeadline(time.Now().Add(s.WatchInterval))
+		}
+		n, from, err := conn.ReadFromUDPAddrPort(buffer)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+				continue
+			}
+			if errors.Is(err, net.ErrClosed) {
+				return err
+			}
+			s.Logf("Read error from %s: %v\n", from, err)
+			continue
+		}
+		s.received.Add(1)
+		for _, fn := range rawCallbacks {
+			fn(buffer[:n])
+		}
+
+		if err := decoder.Decode(buffer[:n], &packet.Datagram); err != nil {
+			malformed := s.malformed.Add(1)
+			s.Logf("Skip malformed UDP block of %d bytes from %s (%d skipped in total): %v", n, from, malformed, err)
+			continue
+		}
+		packet.Index++
+		packet.Raw = buffer[:n]
+		packet.Received = time.Now()
+		packet.From = from
+
+		for _, fn := range callbacks {
+			fn(&packet)
+		}
+		for _, sub := range subscriptions {
+			if !sub.deliver(ctx, &packet) {
+				return nil
+			}
+		}
+	}
+}
+
+func (s *Source) setConn(conn *net.UDPConn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn != nil && s.conn != conn {
+		s.conn.Close()
+	}
+	s.conn = conn
+}
+
+// poll returns changed and valid config or nil
+func (s *Source) poll(watcher *lot_config.ConfigWatcher, lotConfig *lot_config.LiftoffTelemetryConfig) *lot_config.LiftoffTelemetryConfig {
+	newLotConfig, err := watcher.Poll()
+	if err != nil {
+		s.Logf("Failed to reload telemetry configuration: %v", err)
+	}
+	if newLotConfig == nil {
+		return nil
+	}
+	if s.Validate != nil {
+		if err := s.Validate(newLotConfig); err != nil {
+			s.Logf("Ignore changed telemetry configuration: %v", err)
+			return nil
+		}
+	}
+	s.Logf("Liftoff Telemetry Config changed: %+v", newLotConfig)
+	s.mu.Lock()
+	s.lotConfig = newLotConfig
+	s.mu.Unlock()
+	return newLotConfig
+}
+
+// rebind listens on the new endpoint if it is changed, falling back to the old one if it fails
+func (s *Source) rebind(conn *net.UDPConn, newLotConfig *lot_config.LiftoffTelemetryConfig, lotConfig *lot_config.LiftoffTelemetryConfig) (*net.UDPConn, error) {
+	if newLotConfig.Endpoint == lotConfig.Endpoint {
+		return conn, nil
+	}
+	// Close first, new endpoint may differ only by host and need the same port
+	conn.Close()
+	newConn, err := listenUDP(newLotConfig.Endpoint)
+	if err != nil {
+		s.Logf("Failed to listen on changed endpoint %s, keep listening on %s: %v", newLotConfig.Endpoint, lotConfig.Endpoint, err)
+		if newConn, err = listenUDP(lotConfig.Endpoint); err != nil {
+			return nil, err
+		}
+		newLotConfig.Endpoint = lotConfig.Endpoint
+	} else {
+		s.Logf("Liftoff Telemetry UDP server listening on %s\n", newConn.LocalAddr().String())
+	}
+	s.setConn(newConn)
+	return newConn, nil
+}
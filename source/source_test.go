@@ -0,0 +1,158 @@
+package lot_source_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	lot_config "github.com/dladlk/liftoff-telemetry/data"
+	lot_source "github.com/dladlk/liftoff-telemetry/source"
+)
+
+var fields = []string{"Timestamp", "Position", "MotorRPM"}
+
+// startSource runs the source on a random local port and returns connection to send packets to it
+func startSource(t *testing.T, setup func(*lot_source.Source)) (*lot_source.Source, *net.UDPConn, context.CancelFunc) {
+	t.Helper()
+	source := lot_source.New(lot_config.NewLiftoffTelemetryConfig("127.0.0.1:0", fields))
+	source.Logf = t.Logf
+	setup(source)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- source.Run(ctx) }()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("Run() failed: %v", err)
+		}
+	})
+
+	var addr net.Addr
+	for deadline := time.Now().Add(time.Second); addr == nil && time.Now().Before(deadline); {
+		addr = source.LocalAddr()
+		time.Sleep(time.Millisecond)
+	}
+	if addr == nil {
+		t.Fatal("Source is not listening")
+	}
+	conn, err := net.DialUDP("udp", nil, addr.(*net.UDPAddr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return source, conn, cancel
+}
+
+func send(t *testing.T, conn *net.UDPConn, timestamps ...float32) {
+	t.Helper()
+	streamFormats := lot_config.ParseStreamDataTypeFormats(fields)
+	for _, ts := range timestamps {
+		d := lot_config.Datagram{Timestamp: ts, MotorRPM: []float32{1, 2, 3, 4}}
+		if _, err := conn.Write(d.Encode(streamFormats)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func waitReceived(t *testing.T, source *lot_source.Source, want uint64) {
+	t.Helper()
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		if source.Stats().Received >= want {
+			return
+		}
+	}
+	t.Fatalf("Received %v, want %d packets", source.Stats(), want)
+}
+
+func TestSource_Subscribe(t *testing.T) {
+	var block, dropOldest, latest *lot_source.Subscription
+	var called, raw int
+	source, conn, _ := startSource(t, func(s *lot_source.Source) {
+		block = s.Subscribe(10, lot_source.Block)
+		dropOldest = s.Subscribe(2, lot_source.DropOldest)
+		latest = s.Subscribe(10, lot_source.LatestOnly)
+		s.SubscribeFunc(func(p *lot_source.Packet) { called++ })
+		s.SubscribeRaw(func(b []byte) { raw++ })
+	})
+
+	send(t, conn, 1, 2, 3, 4, 5)
+	conn.Write([]byte{1, 2, 3})
+	waitReceived(t, source, 6)
+
+	expect := func(name string, sub *lot_source.Subscription, want ...float32) {
+		for _, ts := range want {
+			select {
+			case p := <-sub.C():
+				if p.Datagram.Timestamp != ts || len(p.Datagram.MotorRPM) != 4 || len(p.Raw) != 33 {
+					t.Errorf("%s: got packet %+v, want timestamp %v", name, p, ts)
+				}
+			case <-time.After(time.Second):
+				t.Fatalf("%s: no packet with timestamp %v", name, ts)
+			}
+		}
+		if len(sub.C()) != 0 {
+			t.Errorf("%s: %d unexpected packets left", name, len(sub.C()))
+		}
+	}
+	expect("block", block, 1, 2, 3, 4, 5)
+	expect("drop oldest", dropOldest, 4, 5)
+	expect("latest only", latest, 5)
+
+	if called != 5 {
+		t.Errorf("Callback called %d times, want 5", called)
+	}
+	if raw != 6 {
+		t.Errorf("Raw callback called %d times, want 6 including malformed packet", raw)
+	}
+	stats := source.Stats()
+	if stats.Received != 6 || stats.Malformed != 1 || stats.Dropped != 3+4 {
+		t.Errorf("Stats() = %v, want received 6, malformed 1, dropped 7", stats)
+	}
+}
+
+func TestSource_Cancel(t *testing.T) {
+	var sub *lot_source.Subscription
+	_, _, cancel := startSource(t, func(s *lot_source.Source) {
+		sub = s.Subscribe(1, lot_source.Block)
+	})
+	cancel()
+	select {
+	case _, ok := <-sub.C():
+		if ok {
+			t.Error("Got packet after cancel")
+		}
+	case <-time.After(time.Second):
+		t.Error("Subscription channel is not closed after cancel")
+	}
+}
+
+func TestSubscription_HeldPacket(t *testing.T) {
+	var dropOldest, latest *lot_source.Subscription
+	source, conn, _ := startSource(t, func(s *lot_source.Source) {
+		dropOldest = s.Subscribe(2, lot_source.DropOldest)
+		latest = s.Subscribe(1, lot_source.LatestOnly)
+	})
+
+	send(t, conn, 1)
+	waitReceived(t, source, 1)
+	heldDropOldest, heldLatest := <-dropOldest.C(), <-latest.C()
+
+	// Packets dropped while the first one is held must not overwrite it
+	send(t, conn, 2, 3, 4, 5, 6, 7)
+	waitReceived(t, source, 7)
+	if heldDropOldest.Datagram.Timestamp != 1 || heldLatest.Datagram.Timestamp != 1 {
+		t.Errorf("Held packets changed to timestamps %v and %v, want 1", heldDropOldest.Datagram.Timestamp, heldLatest.Datagram.Timestamp)
+	}
+	for name, want := range map[string]struct {
+		sub        *lot_source.Subscription
+		timestamps []float32
+	}{"drop oldest": {dropOldest, []float32{6, 7}}, "latest only": {latest, []float32{7}}} {
+		for _, ts := range want.timestamps {
+			if p := <-want.sub.C(); p.Datagram.Timestamp != ts {
+				t.Errorf("%s: got timestamp %v, want %v", name, p.Datagram.Timestamp, ts)
+			}
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package lot_source
+
+import (
+	"context"
+	"net/netip"
+	"slices"
+	"sync/atomic"
+	"time"
+
+	lot_config "github.com/dladlk/liftoff-telemetry/data"
+)
+
+// Policy defines what happens when a subscriber does not keep up with incoming packets
+type Policy int
+
+const (
+	// DropOldest discards the oldest queued packet to make room for the new one
+	DropOldest Policy = iota
+	// Block waits for the subscriber, so the source stops reading and the OS may drop packets instead
+	Block
+	// LatestOnly keeps only the most recent packet
+	LatestOnly
+)
+
+func (p Policy) String() string {
+	switch p {
+	case DropOldest:
+		return "drop-oldest"
+	case Block:
+		return "block"
+	case LatestOnly:
+		return "latest-only"
+	}
+	return "unknown"
+}
+
+// Packet is a decoded datagram together with the raw bytes it was decoded from
+type Packet struct {
+	Datagram lot_config.Datagram
+	Raw      []byte
+	Index    uint64    // Sequence number of valid packet since the source started
+	Received time.Time // Local time when packet was read
+	From     netip.AddrPort
+	Config   *lot_config.LiftoffTelemetryConfig // Config the packet was decoded with, changes on reload
+}
+
+// copyTo copies the packet reusing slices of dst
+func (p *Packet) copyTo(dst *Packet) {
+	datagram := dst.Datagram
+	raw := dst.Raw
+	*dst = *p
+	p.Datagram.CopyTo(&datagram)
+	dst.Datagram = datagram
+	dst.Raw = append(raw[:0], p.Raw...)
+}
+
+// Subscription delivers packets over a channel. Packets are reused by the source,
+// so a received packet is valid only until the next receive from the same channel.
+type Subscription struct {
+	policy  Policy
+	ch      chan *Packet
+	sent    []*Packet // Slots sent and not dropped, oldest first: queued ones and the one held by the subscriber
+	free    []*Packet
+	dropped atomic.Uint64
+}
+
+func newSubscription(size int, policy Policy) *Subscription {
+	if policy == LatestOnly || size < 1 {
+		size = 1
+	}
+	// Packets in the channel, one held by the subscriber and one being filled by the source
+	ring := make([]Packet, size+2)
+	s := &Subscription{policy: policy, ch: make(chan *Packet, size), sent: make([]*Packet, 0, len(ring)), free: make([]*Packet, len(ring))}
+	for i := range ring {
+		s.free[i] = &ring[i]
+	}
+	return s
+}
+
+// C returns the channel, which is closed when the source stops
+func (s *Subscription) C() <-chan *Packet {
+	return s.ch
+}
+
+func (s *Subscription) Policy() Policy {
+	return s.policy
+}
+
+// Dropped returns the number of packets this subscriber lost because of its policy
+func (s *Subscription) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// deliver copies the packet into a free slot and sends it according to policy.
+// Returns false if the context is cancelled while blocked.
+func (s *Subscription) deliver(ctx context.Context, p *Packet) bool {
+	slot := s.take()
+	p.copyTo(slot)
+	s.sent = append(s.sent, slot)
+
+	if s.policy == Block {
+		select {
+		case s.ch <- slot:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+	for {
+		select {
+		case s.ch <- slot:
+			return true
+		default:
+		}
+		// Only the source sends, so after taking one out there is room unless subscriber is reading concurrently
+		select {
+		case dropped := <-s.ch:
+			// Dropped slot was never seen by the subscriber, so it is filled next
+			s.sent = slices.DeleteFunc(s.sent, func(sent *Packet) bool { return sent == dropped })
+			s.free = append(s.free, dropped)
+			s.dropped.Add(1)
+		default:
+		}
+	}
+}
+
+// take returns a slot which is neither queued nor held by the subscriber
+func (s *Subscription) take() *Packet {
+	// Subscriber receives slots in order they were sent and holds only the last received one, so older ones are free.
+	// Length of the channel only decreases concurrently, so at worst a slot received just now is kept for later.
+	if received := len(s.sent) - len(s.ch); received > 1 {
+		s.free = append(s.free, s.sent[:received-1]...)
+		s.sent = append(s.sent[:0], s.sent[received-1:]...)
+	}
+	slot := s.free[len(s.free)-1]
+	s.free = s.free[:len(s.free)-1]
+	return slot
+}
+
+func (s *Subscription) close() {
+	close(s.ch)
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	lot_config "github.com/dladlk/liftoff-telemetry/data"
+)
+
+// Summary of a race session, written as JSON next to its recording to build leaderboards without parsing raw data
+type Summary struct {
+	Version        string                 `json:"version"`
+	Recording      string                 `json:"recording"`
+	Track          string                 `json:"track,omitempty"`
+	Drone          string                 `json:"drone"`
+	StreamFormat   []string               `json:"streamFormat"`
+	FirstTimestamp float32                `json:"firstTimestamp"` // Game time range of the session, seconds
+	LastTimestamp  float32                `json:"lastTimestamp"`
+	Race           Trip                   `json:"race"`
+	Laps           []Trip                 `json:"laps"` // Laps timed by the gate or detected circles
+	Health         lot_config.HealthStats `json:"health"`
+}
+
+// summaryPath returns path of the summary for the recording file
+func summaryPath(recording string) string {
+	return strings.TrimSuffix(recording, filepath.Ext(recording)) + ".json"
+}
+
+func writeSummary(summary *Summary) {
+	path := summaryPath(summary.Recording)
+	b, err := json.MarshalIndent(summary, "", "  ")
+	if err == nil {
+		err = os.WriteFile(path, b, 0666)
+	}
+	if err != nil {
+		log.Printf("Failed to write session summary %s: %v", path, err)
+		return
+	}
+	log.Printf("Session summary is written to file %s", path)
+}
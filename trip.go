@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	lot_config "github.com/dladlk/liftoff-telemetry/data"
+)
+
+type Trip struct {
+	Type            string                 `json:"type"`
+	Start           time.Time              `json:"start"`
+	End             time.Time              `json:"end"`
+	DurationSeconds int                    `json:"durationSeconds"`
+	Events          int32                  `json:"events"`
+	Index           int                    `json:"index"`
+	MaxDistance     float64                `json:"maxDistance"` // From the start position
+	MaxSpeed        float64                `json:"maxSpeed"`
+	MaxGLoad        float64                `json:"maxGLoad"`
+	TripDistance    float64                `json:"tripDistance"`
+	LapTime         float64                `json:"lapTime,omitempty"` // Timed by the start/finish gate, seconds
+	Invalid         string                 `json:"invalid,omitempty"` // Reason why the lap does not count
+	Laps            int                    `json:"laps,omitempty"`    // Valid laps of the race
+	InvalidLaps     int                    `json:"invalidLaps,omitempty"`
+	LapsTime        float64                `json:"lapsTime,omitempty"` // Sum of all lap times of the race
+	BestLap         float64                `json:"bestLap,omitempty"`
+	Sectors         []float64              `json:"sectors,omitempty"`         // Split times of the lap by checkpoints of the track, 0 for missed ones
+	SectorDeltas    []float64              `json:"sectorDeltas,omitempty"`    // Differences to the best sectors before the lap
+	BestSectors     []float64              `json:"bestSectors,omitempty"`     // Best sectors of the race and previous races on the same track
+	TheoreticalBest float64                `json:"theoreticalBest,omitempty"` // Sum of best sectors
+	Crashes         []lot_config.Crash     `json:"crashes,omitempty"`
+	GhostDeltas     []float64              `json:"ghostDeltas,omitempty"` // Deltas to the best lap at the ends of its equal segments, positive if behind
+	Flight          lot_config.FlightStats `json:"flight"`
+	Tricks          []lot_config.Trick     `json:"tricks,omitempty"`
+	StyleScore      float64                `json:"styleScore,omitempty"`
+}
+
+// Report logs stats of the trip finished at given time
+func (this *Trip) Report(end time.Time) {
+	this.End = end
+	duration := this.End.Sub(this.Start)
+	this.DurationSeconds = int(duration.Seconds())
+	var lap string
+	if this.LapTime > 0 {
+		lap = fmt.Sprintf(", lap time %.3f s", this.LapTime)
+		if this.Invalid != "" {
+			lap += " invalid: " + this.Invalid
+		}
+	}
+	if len(this.Sectors) > 0 {
+		lap += ", sectors " + lot_config.FormatSectors(this.Sectors, this.SectorDeltas)
+	}
+	if this.Laps > 0 {
+		lap += fmt.Sprintf(", %d valid laps, best %.3f s", this.Laps, this.BestLap)
+	}
+	if this.TheoreticalBest > 0 {
+		lap += fmt.Sprintf(", best sectors %s, theoretical best %.3f s", lot_config.FormatSectors(this.BestSectors, nil), this.TheoreticalBest)
+	}
+	if len(this.GhostDeltas) > 0 {
+		deltas := make([]string, len(this.GhostDeltas))
+		for i, delta := range this.GhostDeltas {
+			deltas[i] = fmt.Sprintf("%+.3f", delta)
+		}
+		lap += ", ghost deltas " + strings.Join(deltas, " ")
+	}
+	if len(this.Crashes) > 0 {
+		lap += fmt.Sprintf(", %d crashes", len(this.Crashes))
+	}
+	if len(this.Tricks) > 0 {
+		this.StyleScore = lot_config.StyleScore(this.Tricks)
+		lap += fmt.Sprintf(", %d tricks, style score %.1f", len(this.Tricks), this.StyleScore)
+	}
+	if this.Flight.Time > 0 {
+		lap += ", " + this.Flight.String()
+	}
+	log.Printf("%s #%d: %v (%ds), %d events, total %.1f, max speed: %.2f m/s, max g-load: %.1f g, max from start: %.1f%s",
+		this.Type, this.Index, duration.Round(time.Second), this.DurationSeconds, this.Events, this.TripDistance, this.MaxSpeed, this.MaxGLoad, this.MaxDistance, lap)
+}
+
+// AddLap counts lap of the race
+func (this *Trip) AddLap(lap *lot_config.Lap) {
+	this.LapsTime += lap.Time
+	if !lap.Valid() {
+		this.InvalidLaps++
+		return
+	}
+	if this.Laps == 0 || lap.Time < this.BestLap {
+		this.BestLap = lap.Time
+	}
+	this.Laps++
+}
+
+func (this *Trip) AddKinematics(d *lot_config.Datagram, k *lot_config.Kinematics) {
+	this.Flight.Update(d, k.Speed)
+	if this.MaxSpeed < k.Speed {
+		this.MaxSpeed = k.Speed
+	}
+	if k.HasAcceleration && this.MaxGLoad < k.GLoad {
+		this.MaxGLoad = k.GLoad
+	}
+}
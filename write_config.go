@@ -0,0 +1,48 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	lot_config "github.com/dladlk/liftoff-telemetry/data"
+)
+
+// runWriteConfig writes a valid Liftoff TelemetryConfiguration.json with chosen endpoint and fields
+func runWriteConfig(args []string) {
+	flags := flag.NewFlagSet("write-config", flag.ExitOnError)
+	path := flags.String("telemetry-config", "", "Path to Liftoff TelemetryConfiguration.json, overrides discovery")
+	endpoint := flags.String("endpoint", lot_config.DefaultEndpoint, "Endpoint Liftoff sends telemetry to")
+	fields := flags.String("fields", strings.Join(lot_config.AllStreamFormatNames, ","), "Comma separated stream formats to send")
+	flags.Parse(args)
+
+	// Explicit path may not exist yet, it is created then
+	target := *path
+	if target == "" {
+		var err error
+		if target, err = lot_config.FindTelemetryConfig(""); err != nil {
+			log.Fatalf("Failed to find telemetry configuration, pass its path with -telemetry-config: %v", err)
+		}
+	}
+
+	lotConfig := lot_config.NewLiftoffTelemetryConfig(*endpoint, splitNames(*fields))
+	warnings, err := lotConfig.Validate(lot_config.Timestamp, lot_config.Position)
+	for _, warning := range warnings {
+		log.Printf("Warning: %s", warning)
+	}
+	if err != nil {
+		log.Fatalf("Refuse to write telemetry configuration: %v", err)
+	}
+
+	backupPath, err := lot_config.WriteLiftoffTelemetryConfig(target, lotConfig)
+	if backupPath != "" {
+		log.Printf("Original telemetry configuration is backed up to %s", backupPath)
+	}
+	if err != nil {
+		log.Fatalf("Failed to write telemetry configuration %s: %v", target, err)
+	}
+	fmt.Printf("Written %s: %v\n", target, lotConfig)
+	os.Exit(0)
+}
@@ -1,22 +1,23 @@
 package main
 
 import (
-	"bytes"
-	"encoding/binary"
 	"fmt"
 	"log"
+	"math"
 	"os"
+	"strings"
 	"time"
 
 	lot_config "github.com/dladlk/liftoff-telemetry/data"
 )
 
 type Writer struct {
-	logFile     *os.File
-	binWriteBuf *bytes.Buffer
-	binFormat   bool
-	config      *Config
-	lotConfig   *lot_config.LiftoffTelemetryConfig
+	logFile   *os.File
+	writeBuf  []byte
+	binFormat bool
+	csvMotors int // Motor columns of csv header, which is written with the first datagram, negative before it
+	config    *Config
+	lotConfig *lot_config.LiftoffTelemetryConfig
 }
 
 func (t *Writer) Start(config *Config, lotConfig *lot_config.LiftoffTelemetryConfig) {
@@ -28,14 +29,26 @@ func (t *Writer) Start(config *Config, lotConfig *lot_config.LiftoffTelemetryCon
 	if t.binFormat {
 		writeLogToFileExtension = ".bin"
 	}
-	writeLogToFile := fmt.Sprintf("liftoff_telemetry_%s", time.Now().Format("20060102_150405")+writeLogToFileExtension)
+	if config.Output.Dir != "" {
+		if err := os.MkdirAll(config.Output.Dir, 0755); err != nil {
+			log.Fatalf("Failed to create output dir %s: %v", config.Output.Dir, err)
+		}
+	}
+	filePath := config.FilePath(time.Now())
+	writeLogToFile := filePath + writeLogToFileExtension
+	// Restart within the same second must not append to the previous file
+	for i := 2; fileExists(writeLogToFile); i++ {
+		writeLogToFile = fmt.Sprintf("%s_%d%s", filePath, i, writeLogToFileExtension)
+	}
 	logFile, err := os.OpenFile(writeLogToFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0666)
 	if err != nil {
 		log.Fatalf("Failed to create log file %s: %v", writeLogToFile, err)
 	}
 	t.logFile = logFile
-	t.writeHeader()
-	t.binWriteBuf = new(bytes.Buffer)
+	t.csvMotors = -1
+	if t.binFormat {
+		t.writeHeader()
+	}
 }
 
 func (t *Writer) Restart() {
@@ -43,50 +56,76 @@ func (t *Writer) Restart() {
 	t.Start(t.config, t.lotConfig)
 }
 
+// FileName returns path of the file the session is written to
+func (t *Writer) FileName() string {
+	return t.logFile.Name()
+}
+
+// WriteSummary writes summary of the session as JSON file next to the recording
+func (t *Writer) WriteSummary(summary *Summary) {
+	summary.Recording = t.logFile.Name()
+	summary.StreamFormat = t.lotConfig.StreamFormatNames
+	writeSummary(summary)
+}
+
 func (t *Writer) Close() {
 	log.Printf("Session is written to file %s", t.logFile.Name())
 	t.logFile.Close()
 }
 
+// writeHeader writes stream formats of bin recording
 func (t *Writer) writeHeader() {
-	var headerBuffer bytes.Buffer // Declare a bytes.Buffer
-	for i, name := range t.lotConfig.StreamFormatNames {
-		if i > 0 {
-			headerBuffer.WriteString(",")
+	t.logFile.WriteString(strings.Join(t.lotConfig.StreamFormatNames, ",") + "\n")
+}
+
+// writeCsvHeader writes names of csv columns, with a column per motor of the datagram
+func (t *Writer) writeCsvHeader(cur *lot_config.Datagram) {
+	t.csvMotors = 0
+	if t.lotConfig.HasStreamDataType(lot_config.MotorRPM) {
+		t.csvMotors = len(cur.MotorRPM)
+		if t.csvMotors == 0 {
+			t.csvMotors = lot_config.DefaultMotors
 		}
-		headerBuffer.WriteString(name)
 	}
-	headerBuffer.WriteString("\n")
-	t.logFile.Write(headerBuffer.Bytes())
+	columns := lot_config.CsvColumns(t.lotConfig.StreamFormats, t.csvMotors)
+	if t.config.General.Derived {
+		columns = append(columns, "speed", "horizontal_speed", "vertical_speed", "altitude", "acceleration", "g_load", "ghost_delta")
+	}
+	t.logFile.WriteString(strings.Join(columns, ",") + "\n")
 }
 
-func (t *Writer) Write(cur *lot_config.Datagram, curSession *Trip) {
+// Write saves the datagram, derived values are written to csv only. Kinematics are nil for a late packet
+// and ghost delta is NaN if there is no ghost, their columns are left empty.
+func (t *Writer) Write(cur *lot_config.Datagram, kinematics *lot_config.Kinematics, ghostDelta float64, curSession *Trip) {
 	if t.binFormat {
-		for _, f := range t.lotConfig.StreamFormats {
-			t.binWriteBuf.Reset()
-			switch f {
-			case lot_config.Timestamp:
-				binary.Write(t.binWriteBuf, binary.LittleEndian, cur.Timestamp)
-			case lot_config.Position:
-				binary.Write(t.binWriteBuf, binary.LittleEndian, cur.Position)
-			case lot_config.Attitude:
-				binary.Write(t.binWriteBuf, binary.LittleEndian, cur.Attitude)
-			case lot_config.Velocity:
-				binary.Write(t.binWriteBuf, binary.LittleEndian, cur.Velocity)
-			case lot_config.Gyro:
-				binary.Write(t.binWriteBuf, binary.LittleEndian, cur.Gyro)
-			case lot_config.Input:
-				binary.Write(t.binWriteBuf, binary.LittleEndian, cur.Input)
-			case lot_config.Battery:
-				binary.Write(t.binWriteBuf, binary.LittleEndian, cur.Battery)
-			case lot_config.MotorRPM:
-				binary.Write(t.binWriteBuf, binary.LittleEndian, cur.Motors)
-				binary.Write(t.binWriteBuf, binary.LittleEndian, cur.MotorRPM)
-			}
-			t.logFile.Write(t.binWriteBuf.Bytes())
+		t.writeBuf = cur.AppendEncoded(t.writeBuf[:0], t.lotConfig.StreamFormats)
+		t.logFile.Write(t.writeBuf)
+		return
+	}
+	if t.csvMotors >= 0 && len(cur.MotorRPM) > t.csvMotors {
+		// Motors would be cut by the header, so the session goes on in a new file with a column for each of them
+		log.Printf("Datagram has %d motors, csv header has %d - continuing in a new file", len(cur.MotorRPM), t.csvMotors)
+		t.Restart()
+	}
+	if t.csvMotors < 0 {
+		t.writeCsvHeader(cur)
+	}
+	t.writeBuf = cur.AppendCsv(t.writeBuf[:0], curSession.Index, curSession.Events, t.lotConfig.StreamFormats, t.csvMotors)
+	if t.config.General.Derived {
+		if k := kinematics; k != nil {
+			t.writeBuf = fmt.Appendf(t.writeBuf, ",%.3f,%.3f,%.3f,%.3f,%.3f,%.3f,", k.Speed, k.HorizontalSpeed, k.VerticalSpeed, k.Altitude, k.Acceleration, k.GLoad)
+		} else {
+			t.writeBuf = append(t.writeBuf, ",,,,,,,"...)
+		}
+		if !math.IsNaN(ghostDelta) {
+			t.writeBuf = fmt.Appendf(t.writeBuf, "%.3f", ghostDelta)
 		}
-	} else {
-		fmt.Fprintf(t.logFile, "%v,%v,%v,%v,%v,%v,%v,%v,%v\n", curSession.Index, curSession.Events, cur.Timestamp, cur.Position, cur.Attitude, cur.Velocity, cur.Gyro, cur.Input, cur.MotorRPM)
 	}
+	t.writeBuf = append(t.writeBuf, '\n')
+	t.logFile.Write(t.writeBuf)
+}
 
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
 }